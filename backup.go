@@ -0,0 +1,212 @@
+package hue
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// CreateScene creates a new scene containing the given light states and
+// returns its ID.
+func (s *Session) CreateScene(name string, lightStates map[string]LightState) (string, error) {
+	return s.createScene(name, "", lightStates)
+}
+
+// CreateGroupScene creates a new scene for a group containing the given light
+// states, which must be for lights in the group, and returns its ID.
+func (s *Session) CreateGroupScene(name string, group string, lightStates map[string]LightState) (string, error) {
+	return s.createScene(name, group, lightStates)
+}
+
+func (s *Session) createScene(name string, group string, lightStates map[string]LightState) (string, error) {
+	lights := make([]string, 0, len(lightStates))
+	states := map[string]LightState{}
+	for id, state := range lightStates {
+		lights = append(lights, id)
+		// the colormode and reachable flag are read-only
+		state.ColorMode = ""
+		state.Reachable = false
+		states[id] = state
+	}
+	sort.Strings(lights)
+
+	data := map[string]interface{}{
+		"name":        name,
+		"recycle":     false,
+		"lightstates": states,
+	}
+	if group != "" {
+		data["type"] = "GroupScene"
+		data["group"] = group
+	} else {
+		data["type"] = "LightScene"
+		data["lights"] = lights
+	}
+
+	log.Printf("Creating scene: %#v", data)
+	return s.create(s.URL()+"/scenes", &data)
+}
+
+// ExportScenes returns all the scenes on the session's hub, including their
+// stored light states, sorted by ID. The result can be serialized to JSON and
+// later restored with ImportScenes.
+func (s *Session) ExportScenes() ([]SceneDetail, error) {
+	scenes, err := s.Scenes()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(scenes))
+	for id := range scenes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	details := make([]SceneDetail, 0, len(ids))
+	for _, id := range ids {
+		detail, err := s.GetScene(id)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// ImportScenes creates new scenes from scenes previously returned by
+// ExportScenes. Light states for lights that don't exist on the hub are
+// skipped, as are scenes with no remaining lights; group scenes whose group
+// doesn't exist are created as light scenes. The skipped light IDs are
+// returned, keyed by the ID of the exported scene.
+func (s *Session) ImportScenes(details []SceneDetail) (skipped map[string][]string, err error) {
+	lights, err := s.Lights()
+	if err != nil {
+		return
+	}
+	groups, err := s.Groups()
+	if err != nil {
+		return
+	}
+
+	skipped = map[string][]string{}
+	for _, detail := range details {
+		states := map[string]LightState{}
+		for id, state := range detail.LightStates {
+			if _, ok := lights[id]; ok {
+				states[id] = state
+			} else {
+				skipped[detail.ID] = append(skipped[detail.ID], id)
+			}
+		}
+		sort.Strings(skipped[detail.ID])
+
+		if len(states) == 0 {
+			log.Printf("Skipping scene %s with no lights", detail.Name)
+			continue
+		}
+
+		group := ""
+		if _, ok := groups[detail.Group]; ok {
+			group = detail.Group
+		}
+
+		if _, err = s.createScene(detail.Name, group, states); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// sceneSettleTimeout is how long UpgradeScene waits for a recalled scene's
+// lights to reach their new states.
+const sceneSettleTimeout = 5 * time.Second
+
+// UpgradeScene converts a version 1 scene to a version 2 scene and returns the
+// new scene's ID. Version 1 scenes can't be converted in place, so a new scene
+// is created with the old scene's light states and the old scene is deleted.
+// Hubs usually can't report a version 1 scene's light states, in which case
+// the scene is recalled without a transition, and its lights' states are read
+// once they stop changing. If the scene is already a version 2 scene, its ID
+// is returned unchanged.
+func (s *Session) UpgradeScene(id string) (string, error) {
+	scene, err := s.GetScene(id)
+	if err != nil {
+		return "", err
+	}
+	if !scene.IsLegacy() {
+		return id, nil
+	}
+
+	states := scene.LightStates
+	if len(states) < len(scene.Lights) {
+		if states, err = s.recallSceneStates(scene); err != nil {
+			return "", err
+		}
+	}
+
+	newID, err := s.createScene(scene.Name, scene.Group, states)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.delete(s.URL() + "/scenes/" + id)
+	log.Printf("Response: %#v", resp)
+	return newID, err
+}
+
+// recallSceneStates recalls a scene without a transition and returns the
+// resulting states of its lights, once two reads of the lights, at least
+// MinPollInterval apart, agree.
+func (s *Session) recallSceneStates(scene SceneDetail) (map[string]LightState, error) {
+	transition := 0
+	if err := s.SetSceneThen(scene.ID, LightState{TransitionTime: &transition}); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(MinPollInterval)
+	defer ticker.Stop()
+	timeout := time.After(sceneSettleTimeout)
+
+	var previous map[string]LightState
+	for {
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return nil, fmt.Errorf("Lights in scene %s didn't settle after %v", scene.ID, sceneSettleTimeout)
+		}
+
+		lights, err := s.Lights()
+		if err != nil {
+			return nil, err
+		}
+		states := map[string]LightState{}
+		for _, lightID := range scene.Lights {
+			if light, ok := lights[lightID]; ok {
+				states[lightID] = restorableState(light.State)
+			}
+		}
+
+		if previous != nil && statesEqual(states, previous) {
+			return states, nil
+		}
+		previous = states
+	}
+}
+
+// statesEqual returns true if two sets of light states are the same, with the
+// tolerance the hub uses for xy values.
+func statesEqual(a, b map[string]LightState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, state := range a {
+		other, ok := b[id]
+		if !ok || !state.Equal(other, xyEpsilon) {
+			return false
+		}
+	}
+	return true
+}
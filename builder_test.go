@@ -0,0 +1,27 @@
+package hue
+
+import "testing"
+
+func TestStateBuilderRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b int
+		wantBri int
+	}{
+		{"white", 255, 255, 255, MaxBrightness},
+		{"black", 0, 0, 0, MinBrightness},
+		{"gray", 128, 128, 128, 55},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := StateBuilder{}.On().RGB(test.r, test.g, test.b).State()
+			if state.Brightness != test.wantBri {
+				t.Errorf("RGB(%d, %d, %d) brightness = %d, want %d", test.r, test.g, test.b, state.Brightness, test.wantBri)
+			}
+			if err := state.Validate(); err != nil {
+				t.Errorf("RGB(%d, %d, %d) state is invalid: %v", test.r, test.g, test.b, err)
+			}
+		})
+	}
+}
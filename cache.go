@@ -0,0 +1,110 @@
+package hue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+)
+
+// lightsCache holds the most recent lights response from a hub.
+type lightsCache struct {
+	hash   [sha256.Size]byte
+	lights map[string]Light
+}
+
+// SetCaching enables or disables caching of light responses for
+// LightsChanged. Disabling caching discards any cached response.
+func (s *Session) SetCaching(enabled bool) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if enabled {
+		if st.cache == nil {
+			st.cache = &lightsCache{}
+		}
+	} else {
+		st.cache = nil
+	}
+}
+
+// LightsChanged returns the lights on the hub and whether they've changed
+// since the last call. When caching is enabled with SetCaching and the hub's
+// response is identical to the previous one, the previously returned map is
+// returned again without being decoded, so callers shouldn't modify it. When
+// caching is disabled, changed is always true.
+func (s *Session) LightsChanged() (lights map[string]Light, changed bool, err error) {
+	st := s.settings()
+	st.mu.RLock()
+	cache := st.cache
+	st.mu.RUnlock()
+
+	if cache == nil {
+		lights, err = s.Lights()
+		return lights, err == nil, err
+	}
+
+	url := s.URL() + "/lights"
+	var body []byte
+	err = s.withRetries(func() (err error) {
+		ctx, done := s.traceContext(context.Background(), "GET", url)
+		defer done()
+		body, err = restGetRaw(ctx, url)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	hash := sha256.Sum256(body)
+
+	st.mu.RLock()
+	if cache.lights != nil && hash == cache.hash {
+		lights = cache.lights
+		st.mu.RUnlock()
+		return lights, false, nil
+	}
+	st.mu.RUnlock()
+
+	if err = json.Unmarshal(body, &lights); err != nil {
+		return
+	}
+	for id, light := range lights {
+		light.ID = id
+		lights[id] = light
+	}
+
+	st.mu.Lock()
+	cache.hash = hash
+	cache.lights = lights
+	st.mu.Unlock()
+
+	s.rememberLights(lights)
+	return lights, true, nil
+}
+
+// restGetRaw returns the body of a GET request. If the hub responds with an
+// error message instead, it's returned as an *APIError.
+func restGetRaw(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if err := statusError(resp); err != nil {
+		return nil, err
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	return body, responseError(body)
+}
@@ -0,0 +1,159 @@
+package hue
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrWriterClosed is returned when using a CoalescingWriter that has been
+// closed.
+var ErrWriterClosed = errors.New("Writer is closed")
+
+// CoalescingWriter forwards state updates for lights and groups to a hub at a
+// limited rate. Updates may be made at any rate; if several updates for the
+// same light or group arrive before the next send, only the latest is sent.
+// Lights aren't checked for reachability before updates are sent. A
+// CoalescingWriter is safe for concurrent use.
+type CoalescingWriter struct {
+	session  *Session
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[target]LightState
+	order   []target
+	closed  bool
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// target identifies a light or group.
+type target struct {
+	group bool
+	id    string
+}
+
+// NewCoalescingWriter returns a CoalescingWriter that sends at most one update
+// per interval to the session's hub. An interval of 0 uses MinCommandInterval.
+func NewCoalescingWriter(session *Session, interval time.Duration) *CoalescingWriter {
+	if interval <= 0 {
+		interval = MinCommandInterval
+	}
+
+	w := &CoalescingWriter{
+		session:  session,
+		interval: interval,
+		pending:  map[target]LightState{},
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// SetColorXY queues an update setting a light's xy color.
+func (w *CoalescingWriter) SetColorXY(id string, x, y float64) error {
+	return w.queue(target{id: id}, LightState{Xy: [2]float64{x, y}})
+}
+
+// SetGroupColorXY queues an update setting the xy color of a group's lights.
+func (w *CoalescingWriter) SetGroupColorXY(id string, x, y float64) error {
+	return w.queue(target{group: true, id: id}, LightState{Xy: [2]float64{x, y}})
+}
+
+// SetLightState queues an update setting a light's state.
+func (w *CoalescingWriter) SetLightState(id string, state LightState) error {
+	return w.queue(target{id: id}, state)
+}
+
+// SetGroupState queues an update setting the state of a group's lights.
+func (w *CoalescingWriter) SetGroupState(id string, state LightState) error {
+	return w.queue(target{group: true, id: id}, state)
+}
+
+// Flush immediately sends all queued updates, returning the first error
+// encountered.
+func (w *CoalescingWriter) Flush() error {
+	var firstErr error
+	for {
+		ok, err := w.sendNext()
+		if !ok {
+			return firstErr
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+}
+
+// Close stops the writer and sends any queued updates. Updates made after a
+// writer is closed return ErrWriterClosed.
+func (w *CoalescingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	<-w.stopped
+	return w.Flush()
+}
+
+func (w *CoalescingWriter) queue(t target, state LightState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if _, ok := w.pending[t]; !ok {
+		w.order = append(w.order, t)
+	}
+	w.pending[t] = state
+	return nil
+}
+
+// run sends one queued update per interval until the writer is closed.
+func (w *CoalescingWriter) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if _, err := w.sendNext(); err != nil {
+				log.Printf("Error sending update: %v", err)
+			}
+		}
+	}
+}
+
+// sendNext sends the oldest queued update. It returns false if there were no
+// updates to send.
+func (w *CoalescingWriter) sendNext() (ok bool, err error) {
+	w.mu.Lock()
+	if len(w.order) == 0 {
+		w.mu.Unlock()
+		return false, nil
+	}
+	t := w.order[0]
+	w.order = w.order[1:]
+	state := w.pending[t]
+	delete(w.pending, t)
+	w.mu.Unlock()
+
+	if t.group {
+		return true, w.session.SetGroupState(t.id, state)
+	}
+	_, err = w.session.setLightState(t.id, state, false)
+	return true, err
+}
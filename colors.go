@@ -1,6 +1,7 @@
 package hue
 
 import (
+	"fmt"
 	"log"
 	"math"
 )
@@ -10,6 +11,49 @@ type Gamut struct {
 	red   point
 	green point
 	blue  point
+	mode  GamutMode
+}
+
+// GamutMode determines how colors outside of a gamut are brought into it.
+type GamutMode int
+
+const (
+	// ClampToEdge moves out-of-gamut colors to the closest point on the edge
+	// of the gamut.
+	ClampToEdge GamutMode = iota
+
+	// DesaturateToWhite moves out-of-gamut colors toward the white point until
+	// they're in the gamut, preserving their hue.
+	DesaturateToWhite
+)
+
+// whitePoint is the D65 white point.
+var whitePoint = point{0.3127, 0.3290}
+
+// WithMode returns a copy of a gamut that uses the given mode for out-of-gamut
+// colors.
+func (gamut Gamut) WithMode(mode GamutMode) Gamut {
+	gamut.mode = mode
+	return gamut
+}
+
+// NewGamut creates a gamut from the xy coordinates of its red, green, and blue
+// corners.
+func NewGamut(red, green, blue [2]float64) Gamut {
+	return Gamut{
+		red:   point{red[0], red[1]},
+		green: point{green[0], green[1]},
+		blue:  point{blue[0], blue[1]},
+	}
+}
+
+// NewGamutFromPoints creates a gamut from a list of red, green, and blue
+// corners, in that order, as reported in a light's capabilities.
+func NewGamutFromPoints(points [][2]float64) (Gamut, error) {
+	if len(points) != 3 {
+		return Gamut{}, fmt.Errorf("A gamut needs 3 points, got %d", len(points))
+	}
+	return NewGamut(points[0], points[1], points[2]), nil
 }
 
 // GetGamut gets the color gamut for a particular bulb model
@@ -65,22 +109,14 @@ func (gamut *Gamut) ToXyY(r, g, b int) (x, y, Y float64) {
 		y = 0.0
 	}
 
-	// check if (x, y) is contained within the triangle
-	if !gamut.inLampsReach(x, y) {
-		log.Printf("Not in reach")
-		x, y = gamut.closestPointOnTriangle(x, y)
-	}
+	x, y = gamut.Clamp(x, y)
 
 	return
 }
 
 // ToRGB converts an XY value in the CIE into a 24-bit RGB value.
 func (gamut *Gamut) ToRGB(x, y, bri float64) (r, g, b uint8) {
-	// check if (x, y) is contained within the triangle
-	if !gamut.inLampsReach(x, y) {
-		log.Printf("Not in reach")
-		x, y = gamut.closestPointOnTriangle(x, y)
-	}
+	x, y = gamut.Clamp(x, y)
 
 	z := 1.0 - x - y
 	Y := bri
@@ -115,14 +151,127 @@ func (gamut *Gamut) ToRGB(x, y, bri float64) (r, g, b uint8) {
 		rf, gf, bf = rf/max, gf/max, bf/max
 	}
 
+	// Clamp to [0, 1]; points near the edge of the gamut can produce slightly
+	// negative values, which would wrap around when converted to uint8
+	rf, gf, bf = clamp(rf, 0, 1), clamp(gf, 0, 1), clamp(bf, 0, 1)
+
 	// Scale up
-	r = uint8(math.Ceil(rf*255.0 - 0.5))
-	g = uint8(math.Ceil(gf*255.0 - 0.5))
-	b = uint8(math.Ceil(bf*255.0 - 0.5))
+	r, g, b = toByte(rf*255.0), toByte(gf*255.0), toByte(bf*255.0)
 
 	return
 }
 
+// ctToXy converts a color temperature in mireds to a point on the Planckian
+// locus in the CIE xy color space. It uses the cubic spline approximation from
+// Kim et al., which is valid from 1667K to 25000K; temperatures outside that
+// range are clamped to it.
+func ctToXy(mired int) (x, y float64) {
+	if mired <= 0 {
+		mired = 1
+	}
+	t := clamp(1000000.0/float64(mired), 1667, 25000)
+	t2 := t * t
+	t3 := t2 * t
+
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+
+	x2 := x * x
+	x3 := x2 * x
+
+	if t <= 2222 {
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	} else if t <= 4000 {
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	} else {
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+
+	return
+}
+
+// AverageColors returns the combined color of a set of lights as an RGB
+// value. Each light's color, limited to its own gamut, is converted to the
+// linear CIE XYZ color space, where brighter lights have proportionally larger
+// values, and the results are averaged. The result reflects the light the
+// group actually produces; lights that are off don't contribute. If no lights
+// are on, the result is black.
+func AverageColors(lights []Light) (r, g, b int) {
+	var sumX, sumY, sumZ float64
+	var n int
+	for _, light := range lights {
+		if !light.State.IsOn() || light.State.Brightness <= 0 {
+			continue
+		}
+		gamut := light.Gamut()
+		x, y := gamut.Clamp(stateXy(light.State))
+		if y <= 0 {
+			continue
+		}
+
+		Y := float64(light.State.Brightness) / 255.0
+		sumX += (Y / y) * x
+		sumY += Y
+		sumZ += (Y / y) * (1.0 - x - y)
+		n++
+	}
+
+	if n == 0 {
+		return 0, 0, 0
+	}
+
+	// a mix of the lights' colors is within their gamuts, so it's converted
+	// with gamutD, which covers all colors
+	X, Y, Z := sumX/float64(n), sumY/float64(n), sumZ/float64(n)
+	rb, gb, bb := gamutD.ToRGB(X/(X+Y+Z), Y/(X+Y+Z), Y)
+	return int(rb), int(gb), int(bb)
+}
+
+// NearestColor returns the closest color to an RGB value that can be
+// reproduced within the gamut, along with the perceptual distance between the
+// two colors (CIE76 delta E). A delta E of about 2.3 is just noticeable;
+// larger values mean the color can't be faithfully reproduced.
+func (gamut *Gamut) NearestColor(r, g, b int) (nr, ng, nb int, deltaE float64) {
+	x, y, Y := gamut.ToXyY(r, g, b)
+	rb, gb, bb := gamut.ToRGB(x, y, Y)
+	nr, ng, nb = int(rb), int(gb), int(bb)
+
+	l1, a1, b1 := rgbToLab(r, g, b)
+	l2, a2, b2 := rgbToLab(nr, ng, nb)
+	deltaE = math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+	return
+}
+
+// rgbToLab converts an RGB value to the CIE L*a*b* color space, relative to
+// the D65 white point.
+func rgbToLab(r, g, b int) (l, a, bb float64) {
+	linear := func(v int) float64 {
+		f := float64(v) / 255.0
+		if f > 0.04045 {
+			return math.Pow((f+0.055)/(1.0+0.055), 2.4)
+		}
+		return f / 12.92
+	}
+	rf, gf, bf := linear(r), linear(g), linear(b)
+
+	X := rf*0.664511 + gf*0.154324 + bf*0.162028
+	Y := rf*0.283881 + gf*0.668433 + bf*0.047685
+	Z := rf*0.000088 + gf*0.072310 + bf*0.986039
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(X/0.95047), f(Y), f(Z/1.08883)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
 // ToHSL converts an XY value in the CIE into an HSL value.
 func (gamut *Gamut) ToHSL(x, y, bri float64) (h, s, l float64) {
 	r, g, b := gamut.ToRGB(x, y, bri)
@@ -172,9 +321,152 @@ func rgbToHsl(r, g, b uint8) (h, s, l float64) {
 	return
 }
 
-// inLampsReach returns true if the given point is in the lamp's color space
-// (assuming a Hue bulb).
-func (gamut *Gamut) inLampsReach(x, y float64) bool {
+// Convert an RGB value to an HSV value, where H is in [0, 360], S is in [0, 1],
+// and V is in [0, 1]
+func rgbToHsv(r, g, b uint8) (h, s, v float64) {
+	rf := float64(r) / 255.0
+	gf := float64(g) / 255.0
+	bf := float64(b) / 255.0
+
+	max := math.Max(math.Max(rf, gf), bf)
+	min := math.Min(math.Min(rf, gf), bf)
+	d := max - min
+
+	v = max
+	if max > 0 {
+		s = d / max
+	}
+
+	if d > 0 {
+		switch max {
+		case rf:
+			h = (gf - bf) / d
+			if gf < bf {
+				h += 6
+			}
+		case gf:
+			h = (bf-rf)/d + 2.0
+		case bf:
+			h = (rf-gf)/d + 4.0
+		}
+		h *= 60.0
+	}
+
+	return
+}
+
+// Convert an HSL value, where H is in [0, 360], S is in [0, 1], and L is in
+// [0, 1], to an RGB value
+func hslToRgb(h, s, l float64) (r, g, b uint8) {
+	s, l = clamp(s, 0, 1), clamp(l, 0, 1)
+	v := l + s*math.Min(l, 1-l)
+	sv := 0.0
+	if v > 0 {
+		sv = 2 * (1 - l/v)
+	}
+	return hsvToRgb(h, sv, v)
+}
+
+// rotateHue returns an RGB color with its hue rotated by the given number of
+// degrees.
+func rotateHue(r, g, b uint8, degrees float64) [3]uint8 {
+	h, s, l := rgbToHsl(r, g, b)
+	rr, gg, bb := hslToRgb(h+degrees, s, l)
+	return [3]uint8{rr, gg, bb}
+}
+
+// Complementary returns the color opposite an RGB color on the color wheel.
+func Complementary(r, g, b uint8) (uint8, uint8, uint8) {
+	c := rotateHue(r, g, b, 180)
+	return c[0], c[1], c[2]
+}
+
+// Analogous returns n colors near an RGB color on the color wheel. The colors
+// are 30 degrees apart, alternating on either side of the given color.
+func Analogous(r, g, b uint8, n int) [][3]uint8 {
+	colors := make([][3]uint8, 0, n)
+	for i := 0; i < n; i++ {
+		step := float64(i/2+1) * 30
+		if i%2 == 1 {
+			step = -step
+		}
+		colors = append(colors, rotateHue(r, g, b, step))
+	}
+	return colors
+}
+
+// Triadic returns the two colors that form an evenly spaced triad with an RGB
+// color on the color wheel.
+func Triadic(r, g, b uint8) [2][3]uint8 {
+	return [2][3]uint8{rotateHue(r, g, b, 120), rotateHue(r, g, b, 240)}
+}
+
+// Convert an HSV value, where H is in [0, 360], S is in [0, 1], and V is in
+// [0, 1], to an RGB value
+func hsvToRgb(h, s, v float64) (r, g, b uint8) {
+	h = math.Mod(h, 360.0)
+	if h < 0 {
+		h += 360.0
+	}
+	s, v = clamp(s, 0, 1), clamp(v, 0, 1)
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60.0, 2)-1))
+	m := v - c
+
+	var rf, gf, bf float64
+	switch {
+	case h < 60:
+		rf, gf, bf = c, x, 0
+	case h < 120:
+		rf, gf, bf = x, c, 0
+	case h < 180:
+		rf, gf, bf = 0, c, x
+	case h < 240:
+		rf, gf, bf = 0, x, c
+	case h < 300:
+		rf, gf, bf = x, 0, c
+	default:
+		rf, gf, bf = c, 0, x
+	}
+
+	r, g, b = toByte((rf+m)*255.0), toByte((gf+m)*255.0), toByte((bf+m)*255.0)
+	return
+}
+
+// Clamp returns the given point if it's in the gamut, or otherwise a point in
+// the gamut chosen according to the gamut's mode.
+func (gamut *Gamut) Clamp(x, y float64) (float64, float64) {
+	// check if (x, y) is contained within the triangle
+	if gamut.Contains(x, y) {
+		return x, y
+	}
+
+	log.Printf("Not in reach")
+	if gamut.mode == DesaturateToWhite && gamut.Contains(whitePoint.x, whitePoint.y) {
+		return gamut.closestPointToWhite(x, y)
+	}
+	return gamut.closestPointOnTriangle(x, y)
+}
+
+// closestPointToWhite returns the point where the line from the white point to
+// a given point crosses the edge of the color triangle.
+func (gamut *Gamut) closestPointToWhite(x, y float64) (float64, float64) {
+	// binary search for the furthest point along the line that's in the gamut
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 32; i++ {
+		t := (lo + hi) / 2
+		if gamut.Contains(whitePoint.x+(x-whitePoint.x)*t, whitePoint.y+(y-whitePoint.y)*t) {
+			lo = t
+		} else {
+			hi = t
+		}
+	}
+	return whitePoint.x + (x-whitePoint.x)*lo, whitePoint.y + (y-whitePoint.y)*lo
+}
+
+// Contains returns true if the given point is in the gamut.
+func (gamut *Gamut) Contains(x, y float64) bool {
 	red := gamut.red
 	green := gamut.green
 	blue := gamut.blue
@@ -269,6 +561,20 @@ func closestPointOnLine(a point, b point, p point) point {
 	return point{a.x + ab.x*t, a.y + ab.y*t}
 }
 
+// toByte rounds a color channel value to the nearest integer, with halves
+// rounded up, and clamps it to [0, 255].
+func toByte(v float64) uint8 {
+	return uint8(clamp(math.Round(v), 0, 255))
+}
+
+// clamp limits v to the range [min, max]. NaN values are clamped to min.
+func clamp(v, min, max float64) float64 {
+	if math.IsNaN(v) {
+		return min
+	}
+	return math.Max(min, math.Min(max, v))
+}
+
 // distance returns the magnitude of the distance from point a to point b.
 func distance(a point, b point) float64 {
 	dx := a.x - b.x
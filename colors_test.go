@@ -0,0 +1,132 @@
+package hue
+
+import "testing"
+
+func TestToRGBOutsideGamut(t *testing.T) {
+	gamut := GetGamut("LCT015")
+
+	// Points outside the gamut produce slightly negative channel values
+	// before clamping, which must come out as 0 rather than wrapping to 255.
+	tests := []struct {
+		name string
+		x, y float64
+		bri  float64
+		want [3]uint8
+	}{
+		{"dim red", 0.8, 0.3, 0.001, [3]uint8{15, 0, 0}},
+		{"dim green", 0.1, 0.9, 0.001, [3]uint8{0, 5, 0}},
+		{"dim blue", 0.1, 0.01, 0.001, [3]uint8{0, 0, 85}},
+		{"dim red edge", 0.7, 0.29, 0.01, [3]uint8{54, 0, 1}},
+		{"bright green", 0.1, 0.9, 1, [3]uint8{0, 255, 0}},
+		{"bright blue", 0.1, 0.01, 1, [3]uint8{0, 0, 255}},
+		{"black", 0.8, 0.3, 0, [3]uint8{0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, g, b := gamut.ToRGB(test.x, test.y, test.bri)
+			if got := [3]uint8{r, g, b}; got != test.want {
+				t.Errorf("ToRGB(%v, %v, %v) = %v, want %v", test.x, test.y, test.bri, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToByte(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want uint8
+	}{
+		{-0.5, 0},
+		{0, 0},
+		{0.49, 0},
+		{0.5, 1},
+		{127.49, 127},
+		{127.5, 128},
+		{254.49, 254},
+		{254.5, 255},
+		{255.4, 255},
+		{300, 255},
+	}
+
+	for _, test := range tests {
+		if got := toByte(test.v); got != test.want {
+			t.Errorf("toByte(%v) = %d, want %d", test.v, got, test.want)
+		}
+	}
+}
+
+func TestRGBRoundTrip(t *testing.T) {
+	gamut := GetGamut("LCT015")
+
+	// Colors inside the gamut survive conversion to xyY and back, within
+	// rounding error.
+	tests := [][3]int{
+		{255, 255, 255},
+		{128, 128, 128},
+		{255, 128, 0},
+		{64, 128, 255},
+		{200, 100, 150},
+	}
+
+	for _, rgb := range tests {
+		x, y, Y := gamut.ToXyY(rgb[0], rgb[1], rgb[2])
+		r, g, b := gamut.ToRGB(x, y, Y)
+		got := [3]int{int(r), int(g), int(b)}
+		for i := range got {
+			if diff := got[i] - rgb[i]; diff < -1 || diff > 1 {
+				t.Errorf("RGB%v -> xyY(%.4f, %.4f, %.4f) -> RGB%v", rgb, x, y, Y, got)
+				break
+			}
+		}
+	}
+}
+
+func TestAverageColors(t *testing.T) {
+	light := func(model string, bri int, on bool, xy [2]float64) Light {
+		var l Light
+		l.Model = model
+		l.State = LightState{On: Bool(on), Brightness: bri, Xy: xy, ColorMode: ColorModeXY}
+		return l
+	}
+	red := [2]float64{0.68, 0.31}
+	outsideA := [2]float64{0.1, 0.9}
+
+	tests := []struct {
+		name   string
+		lights []Light
+		want   []Light
+	}{
+		{
+			"brightness counted once",
+			[]Light{light("LCT015", 60, true, red), light("LCT015", 20, true, red)},
+			[]Light{light("LCT015", 40, true, red)},
+		},
+		{
+			"off lights ignored",
+			[]Light{light("LCT015", 200, true, red), light("LCT015", 254, false, outsideA)},
+			[]Light{light("LCT015", 200, true, red)},
+		},
+		{
+			"own gamut",
+			[]Light{light("LST001", 254, true, outsideA)},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, g, b := AverageColors(test.lights)
+			var want [3]int
+			if test.want != nil {
+				want[0], want[1], want[2] = AverageColors(test.want)
+			} else {
+				wr, wg, wb := test.lights[0].GetColorRGB()
+				want = [3]int{int(wr), int(wg), int(wb)}
+			}
+			if got := [3]int{r, g, b}; got != want {
+				t.Errorf("AverageColors() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package hue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Config describes a hub's configuration.
+type Config struct {
+	Name          string                    `json:"name"`
+	BridgeID      string                    `json:"bridgeid"`
+	ZigbeeChannel int                       `json:"zigbeechannel"`
+	MacAddress    string                    `json:"mac"`
+	IPAddress     string                    `json:"ipaddress"`
+	ModelID       string                    `json:"modelid"`
+	SwVersion     string                    `json:"swversion"`
+	APIVersion    string                    `json:"apiversion"`
+	SwUpdate      SwUpdate                  `json:"swupdate2"`
+	Backup        Backup                    `json:"backup"`
+	Timezone      string                    `json:"timezone"`
+	Whitelist     map[string]WhitelistEntry `json:"whitelist"`
+	UTC           string                    `json:"UTC"`
+	LocalTime     string                    `json:"localtime"`
+}
+
+// PublicConfig is the subset of a hub's configuration that can be read
+// without a username.
+type PublicConfig struct {
+	Name             string `json:"name"`
+	BridgeID         string `json:"bridgeid"`
+	MacAddress       string `json:"mac"`
+	ModelID          string `json:"modelid"`
+	SwVersion        string `json:"swversion"`
+	APIVersion       string `json:"apiversion"`
+	DatastoreVersion string `json:"datastoreversion"`
+}
+
+// GetBridgeConfig returns the public configuration of the hub at an IP
+// address. It doesn't require a username, so it can be used to identify a hub
+// before pairing with it.
+func GetBridgeConfig(ipAddress string) (config PublicConfig, err error) {
+	if err = restGet(context.Background(), "http://"+ipAddress+"/api/0/config", &config); err != nil {
+		return
+	}
+	if config.BridgeID == "" {
+		err = fmt.Errorf("No hub at %s", ipAddress)
+	}
+	return
+}
+
+// WhitelistEntry describes a user that is authorized to use a hub. Entries are
+// keyed by username in Config.Whitelist.
+type WhitelistEntry struct {
+	Name        string  `json:"name"`
+	CreateDate  HueTime `json:"create date"`
+	LastUseDate HueTime `json:"last use date"`
+}
+
+// hubTimeFormat is the format of times reported by hubs.
+const hubTimeFormat = "2006-01-02T15:04:05"
+
+// SwUpdate describes the state of a hub's software updates. State is one of
+// "noupdates", "transferring", "anyreadytoinstall", "allreadytoinstall", or
+// "installing".
+type SwUpdate struct {
+	State      string  `json:"state"`
+	LastChange HueTime `json:"lastchange"`
+	Bridge     struct {
+		State       string  `json:"state"`
+		LastInstall HueTime `json:"lastinstall"`
+	} `json:"bridge"`
+}
+
+// Backup describes the state of a hub's configuration backup, which is used
+// to migrate a hub's configuration to a new hub.
+type Backup struct {
+	Status    string `json:"status"`
+	ErrorCode int    `json:"errorcode"`
+}
+
+// Values of Backup.Status. A backup moves from BackupStartMigration to
+// BackupFileReady once the backup file has been created; the restore is then
+// started from the new hub.
+const (
+	BackupIdle           = "idle"
+	BackupStartMigration = "startmigration"
+	BackupFileReady      = "fileready_disabled"
+	BackupPrepareRestore = "prepare_restore"
+	BackupRestoring      = "restoring"
+)
+
+// Config returns the configuration of the session's hub.
+func (s *Session) Config() (config Config, err error) {
+	err = s.get(s.URL()+"/config", &config)
+	return
+}
+
+// Hub models.
+const (
+	BridgeModelV1 = "BSB001" // the original, round hub
+	BridgeModelV2 = "BSB002" // the square hub
+)
+
+// ErrUnsupportedByBridge is returned by methods that need a feature, such as
+// the CLIP v2 API, that the session's hub doesn't support.
+var ErrUnsupportedByBridge = errors.New("Not supported by this hub")
+
+// BridgeModel returns the model ID of the session's hub, such as
+// BridgeModelV1 or BridgeModelV2. The model is cached after it's first read.
+func (s *Session) BridgeModel() (string, error) {
+	st := s.settings()
+	st.mu.RLock()
+	model := st.bridgeModel
+	st.mu.RUnlock()
+	if model != "" {
+		return model, nil
+	}
+
+	config, err := s.Config()
+	if err != nil {
+		return "", err
+	}
+
+	st.mu.Lock()
+	st.bridgeModel = config.ModelID
+	st.mu.Unlock()
+	return config.ModelID, nil
+}
+
+// SoftwareUpdateStatus returns the software update state of the session's
+// hub.
+func (s *Session) SoftwareUpdateStatus() (SwUpdate, error) {
+	config, err := s.Config()
+	return config.SwUpdate, err
+}
+
+// InstallSoftwareUpdate tells the hub to install any software updates that
+// are ready to install.
+func (s *Session) InstallSoftwareUpdate() error {
+	data := map[string]interface{}{
+		"swupdate2": map[string]bool{"install": true},
+	}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// StartBackup tells the hub to create a configuration backup for migrating to
+// a new hub. The hub is locked and stops responding to most commands while the
+// backup runs, and once the backup is ready the hub stays disabled until the
+// configuration has been restored on the new hub. Use BackupStatus to follow
+// the backup's progress.
+func (s *Session) StartBackup() error {
+	data := map[string]interface{}{
+		"backup": map[string]string{"status": BackupStartMigration},
+	}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// BackupStatus returns the state of the hub's configuration backup.
+func (s *Session) BackupStatus() (Backup, error) {
+	config, err := s.Config()
+	return config.Backup, err
+}
+
+// Touchlink tells the hub to perform a touchlink, which takes over lights
+// that are close to the hub, even if they're paired with another hub.
+func (s *Session) Touchlink() error {
+	data := map[string]bool{"touchlink": true}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// Timezone returns the timezone of the session's hub.
+func (s *Session) Timezone() (string, error) {
+	config, err := s.Config()
+	return config.Timezone, err
+}
+
+// SetTimezone sets the timezone of the session's hub. The timezone must be one
+// of the Olson timezone names supported by the hub, such as
+// "America/New_York".
+func (s *Session) SetTimezone(tz string) error {
+	timezones, err := s.Timezones()
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	for _, t := range timezones {
+		if t == tz {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("Timezone '%s' is not supported by the hub", tz)
+	}
+
+	data := map[string]string{"timezone": tz}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// LocalTime returns the current time on the session's hub. The time is in the
+// hub's timezone if it's known locally, or UTC otherwise.
+func (s *Session) LocalTime() (time.Time, error) {
+	config, err := s.Config()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		log.Printf("Unknown timezone '%s', using UTC", config.Timezone)
+		loc = time.UTC
+	}
+
+	return time.ParseInLocation(hubTimeFormat, config.LocalTime, loc)
+}
+
+// Timezones returns the Olson timezone names supported by the session's hub.
+// The list only changes with the hub's firmware, so it's cached after it's
+// first read.
+func (s *Session) Timezones() ([]string, error) {
+	st := s.settings()
+	st.mu.RLock()
+	timezones := st.timezones
+	st.mu.RUnlock()
+
+	if timezones == nil {
+		if err := s.get(s.URL()+"/info/timezones", &timezones); err != nil {
+			return nil, err
+		}
+		st.mu.Lock()
+		st.timezones = timezones
+		st.mu.Unlock()
+	}
+
+	return append([]string(nil), timezones...), nil
+}
+
+// ZigbeeChannels are the Zigbee channels a hub can use.
+var ZigbeeChannels = []int{11, 15, 20, 25}
+
+// ZigbeeChannel returns the Zigbee channel used by the session's hub.
+func (s *Session) ZigbeeChannel() (int, error) {
+	config, err := s.Config()
+	return config.ZigbeeChannel, err
+}
+
+// SetZigbeeChannel sets the Zigbee channel used by the session's hub, which
+// must be one of ZigbeeChannels. Changing the channel briefly disconnects all
+// of the hub's lights and other devices while they move to the new channel,
+// and some devices may need to be power cycled to reconnect.
+func (s *Session) SetZigbeeChannel(channel int) error {
+	valid := false
+	for _, c := range ZigbeeChannels {
+		if c == channel {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("Invalid Zigbee channel %d, must be one of %v", channel, ZigbeeChannels)
+	}
+
+	data := map[string]int{"zigbeechannel": channel}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// ResourceCapacity is the number of resources of a type that a hub can hold.
+type ResourceCapacity struct {
+	Available int `json:"available"`
+	Total     int `json:"total"`
+}
+
+// HubCapabilities describes how many of each type of resource a hub can hold.
+type HubCapabilities struct {
+	Lights        ResourceCapacity `json:"lights"`
+	Sensors       ResourceCapacity `json:"sensors"`
+	Groups        ResourceCapacity `json:"groups"`
+	Scenes        ResourceCapacity `json:"scenes"`
+	Schedules     ResourceCapacity `json:"schedules"`
+	Rules         ResourceCapacity `json:"rules"`
+	ResourceLinks ResourceCapacity `json:"resourcelinks"`
+}
+
+// Capabilities returns the number of each type of resource the session's hub
+// can hold, and how many more can be created.
+func (s *Session) Capabilities() (capabilities HubCapabilities, err error) {
+	err = s.get(s.URL()+"/capabilities", &capabilities)
+	return
+}
+
+// Limits on the length of a hub's name.
+const (
+	minBridgeNameLength = 4
+	maxBridgeNameLength = 16
+)
+
+// BridgeName returns the name of the session's hub.
+func (s *Session) BridgeName() (string, error) {
+	config, err := s.Config()
+	return config.Name, err
+}
+
+// SetBridgeName sets the name of the session's hub. Names must be 4 to 16
+// characters long.
+func (s *Session) SetBridgeName(name string) error {
+	if len(name) < minBridgeNameLength || len(name) > maxBridgeNameLength {
+		return fmt.Errorf("Invalid hub name '%s', must be %d to %d characters", name, minBridgeNameLength, maxBridgeNameLength)
+	}
+
+	data := map[string]string{"name": name}
+	resp, err := s.put(s.URL()+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
@@ -0,0 +1,84 @@
+package hue
+
+import "sort"
+
+// LightChange describes how a light's state changes.
+type LightChange struct {
+	ID     string
+	Before LightState
+	After  LightState
+}
+
+// Changed returns true if a change makes a visible difference to a light.
+func (c LightChange) Changed() bool {
+	return !c.Before.Equal(c.After, xyEpsilon)
+}
+
+// SceneDiff returns how recalling a scene would change the current states of
+// its lights, sorted by light ID. Lights that aren't in the scene are omitted,
+// and lights in the scene that wouldn't change are included, so each light's
+// Changed method tells whether it would be affected. Lights in the scene that
+// aren't on the hub are skipped.
+func (s *Session) SceneDiff(sceneID string) ([]LightChange, error) {
+	scene, err := s.GetScene(sceneID)
+	if err != nil {
+		return nil, err
+	}
+	lights, err := s.Lights()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []LightChange
+	for id, state := range scene.LightStates {
+		light, ok := lights[id]
+		if !ok {
+			continue
+		}
+		before := light.State.Clone()
+		changes = append(changes, LightChange{
+			ID:     id,
+			Before: before,
+			After:  applyState(before, state),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+	return changes, nil
+}
+
+// applyState returns the state a light would have after an update is applied
+// to its current state.
+func applyState(current, update LightState) LightState {
+	state := current.Clone()
+	if update.On != nil {
+		state.On = Bool(*update.On)
+	}
+	if update.Brightness != 0 {
+		state.Brightness = update.Brightness
+	}
+	if update.Effect != "" {
+		state.Effect = update.Effect
+	}
+
+	// the hub gives xy precedence over ct, and ct over hue and saturation
+	switch {
+	case update.Xy != [2]float64{}:
+		state.Xy = update.Xy
+		state.ColorMode = ColorModeXY
+	case update.Ct != 0:
+		state.Ct = update.Ct
+		state.ColorMode = ColorModeCT
+	case update.Hue != 0 || update.Saturation != 0:
+		if update.Hue != 0 {
+			state.Hue = update.Hue
+		}
+		if update.Saturation != 0 {
+			state.Saturation = update.Saturation
+		}
+		state.ColorMode = ColorModeHS
+	}
+	return state
+}
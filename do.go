@@ -0,0 +1,61 @@
+package hue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// Do sends a request to an arbitrary hub API endpoint and returns the raw
+// JSON response. The path is relative to the session's API URL (e.g.,
+// "/lights/1"), and body, if not nil, is encoded as JSON. If the hub reports an
+// error, it is returned as an *APIError along with the response. Requests are
+// subject to the session's retry setting, and requests other than GETs to its
+// dry-run setting.
+//
+// Do is intended for endpoints that this package doesn't otherwise support.
+func (s *Session) Do(method string, path string, body interface{}) (json.RawMessage, error) {
+	method = strings.ToUpper(method)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := s.URL() + path
+
+	if method != "GET" && s.recordDryRun(method, url, body) {
+		return nil, nil
+	}
+
+	var resp []byte
+	send := func() (err error) {
+		ctx, done := s.traceContext(context.Background(), method, url)
+		defer done()
+		if resp, err = restSend(ctx, url, body, method); err != nil {
+			return err
+		}
+		return responseError(resp)
+	}
+
+	return resp, s.withRetries(send)
+}
+
+// responseError returns the first error in a hub response, if any. Responses
+// that aren't lists of messages, such as resource descriptions, have no
+// errors.
+func responseError(body []byte) error {
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		return nil
+	}
+
+	var messages []restResponse
+	if json.Unmarshal(trimmed, &messages) != nil {
+		return nil
+	}
+	for _, message := range messages {
+		if message.Error != nil {
+			return message.Error
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Values for LightState.Alert.
+const (
+	AlertNone    = "none"
+	AlertSelect  = "select"
+	AlertLSelect = "lselect"
+)
+
+// Values for LightState.Effect. While a light's effect is EffectColorLoop,
+// the light keeps cycling through colors, and a new color may be overridden by
+// the loop; setting the effect to EffectNone in the same update stops the loop
+// so the color sticks. The Light and StateBuilder color setters do this
+// automatically.
+const (
+	EffectNone      = "none"
+	EffectColorLoop = "colorloop"
+)
+
+// ClearEffect stops any effect, such as a color loop, running on a light.
+func (s *Session) ClearEffect(id string) error {
+	return s.SetLightState(id, LightState{Effect: EffectNone})
+}
+
+// GroupBreathe makes all the lights in a group breathe (smoothly dim and
+// brighten) for 15 seconds. Unlike a single light, where each light would
+// breathe on its own schedule, the hub pulses all the members of a group
+// together. Use GroupAlertNone to stop breathing early.
+func (s *Session) GroupBreathe(id string) error {
+	return s.SetGroupState(id, LightState{Alert: AlertLSelect})
+}
+
+// GroupAlertNone stops any alert running on a group's lights.
+func (s *Session) GroupAlertNone(id string) error {
+	return s.SetGroupState(id, LightState{Alert: AlertNone})
+}
+
+// GroupColorloop starts or stops a color loop on all the lights in a group.
+// Each light cycles through all the hues it supports, keeping its current
+// brightness and saturation; lights that don't support color are unaffected.
+// The loop continues until it's stopped or a light's color is changed.
+func (s *Session) GroupColorloop(id string, on bool) error {
+	effect := EffectNone
+	if on {
+		effect = EffectColorLoop
+	}
+	return s.SetGroupState(id, LightState{Effect: effect})
+}
+
+// ColorLoopBounded cycles a light's hue back and forth between hueStart and
+// hueEnd (0 to MaxHue), taking period to go from one end and back. If hueEnd
+// is less than hueStart, the loop passes through red (MaxHue wrapping to 0).
+// Unlike the hub's colorloop effect, the loop is driven by this method, which
+// sends an update every MinCommandInterval until ctx is cancelled. The light's
+// previous state is then restored. If the session checks reachability, it's
+// only checked before the loop starts.
+func (s *Session) ColorLoopBounded(ctx context.Context, id string, hueStart, hueEnd int, period time.Duration) error {
+	if hueStart < 0 || hueStart > MaxHue || hueEnd < 0 || hueEnd > MaxHue {
+		return fmt.Errorf("Invalid hue range %d to %d", hueStart, hueEnd)
+	}
+	if period < 2*MinCommandInterval {
+		return fmt.Errorf("Period must be at least %v", 2*MinCommandInterval)
+	}
+
+	light, err := s.GetLight(id)
+	if err != nil {
+		return err
+	}
+	if err = s.reachableError(map[string]Light{id: light}, id); err != nil {
+		return err
+	}
+	previous := restorableState(light.State)
+
+	saturation := light.State.Saturation
+	if saturation == 0 || light.State.ColorMode != ColorModeHS {
+		saturation = MaxSaturation
+	}
+
+	span := (hueEnd - hueStart + MaxHue + 1) % (MaxHue + 1)
+	transition := int(MinCommandInterval / (100 * time.Millisecond))
+	ticker := time.NewTicker(MinCommandInterval)
+	defer ticker.Stop()
+	start := time.Now()
+
+	for {
+		// t moves from 0 to 1 and back to 0 over each period
+		t := math.Mod(float64(time.Since(start))/float64(period), 1) * 2
+		if t > 1 {
+			t = 2 - t
+		}
+		hue := (hueStart + int(math.Round(float64(span)*t))) % (MaxHue + 1)
+
+		state := LightState{
+			Hue:            sendableHue(hue),
+			Saturation:     saturation,
+			Effect:         EffectNone,
+			TransitionTime: &transition,
+		}
+		if _, err := s.setLightState(id, state, false); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			_, err := s.setLightState(id, previous, false)
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// restorableState returns the parts of a light's state that can be sent back
+// to the light to restore it. Only the color values for the light's current
+// color mode are included, since the hub would otherwise pick one.
+func restorableState(state LightState) LightState {
+	restored := LightState{On: state.On, Brightness: state.Brightness}
+	switch state.ColorMode {
+	case ColorModeHS:
+		restored.Hue = state.Hue
+		restored.Saturation = state.Saturation
+	case ColorModeCT:
+		restored.Ct = state.Ct
+	case ColorModeXY:
+		restored.Xy = state.Xy
+	}
+	return restored
+}
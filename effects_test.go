@@ -0,0 +1,43 @@
+package hue_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	hue "github.com/jason0x43/go-hue"
+	"github.com/jason0x43/go-hue/hue/huetest"
+)
+
+func TestColorLoopBoundedThroughZero(t *testing.T) {
+	bridge := huetest.NewMockBridge()
+	defer bridge.Close()
+	bridge.AddLight("1", "Lamp")
+	session := bridge.Session()
+	session.SetDryRun(true)
+
+	// With a span of 1 from MaxHue, the loop is at hue 0 halfway through each
+	// period, which is where the second frame lands.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*hue.MinCommandInterval/2)
+	defer cancel()
+	period := 2 * hue.MinCommandInterval
+	if err := session.ColorLoopBounded(ctx, "1", hue.MaxHue, 0, period); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := session.RecordedCommands()
+	if len(commands) < 3 {
+		t.Fatalf("ColorLoopBounded() sent %d commands, want at least 3", len(commands))
+	}
+
+	// the last command restores the light's previous state
+	for _, command := range commands[:len(commands)-1] {
+		var state map[string]interface{}
+		if err := json.Unmarshal([]byte(command.Body), &state); err != nil {
+			t.Fatal(err)
+		}
+		if state["hue"] != float64(hue.MaxHue) {
+			t.Errorf("ColorLoopBounded() sent %s, want hue %d", command.Body, hue.MaxHue)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package hue
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// MinCommandInterval is the minimum time between commands sent to a single
+// light. Hubs can only process about 10 light commands per second.
+const MinCommandInterval = 100 * time.Millisecond
+
+// MinGroupCommandInterval is the minimum time between commands sent to a
+// group. Hubs can only process about 1 group command per second.
+const MinGroupCommandInterval = time.Second
+
+// Easing maps the fraction of a fade's duration that has elapsed, from 0 to 1,
+// to the fraction of the change that should have been applied.
+type Easing func(t float64) float64
+
+// Linear applies a change at a constant rate.
+func Linear(t float64) float64 {
+	return t
+}
+
+// EaseInOut applies a change slowly at the start and end, and quickly in the
+// middle.
+func EaseInOut(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// FadeOptions control how a fade is performed.
+type FadeOptions struct {
+	// Easing determines the rate of change; the default is Linear.
+	Easing Easing
+
+	// Perceptual interpolates brightness in perceived lightness (CIE L*)
+	// rather than linearly in the hub's brightness scale, which makes dimming
+	// look even. Without it, most of the visible change in a fade happens at
+	// low brightness.
+	Perceptual bool
+
+	// Dither alternates between adjacent brightness values over successive
+	// steps to approximate the brightness levels in between, which reduces
+	// visible banding in long, slow fades. It only helps when there are more
+	// steps than brightness levels to cover, so it's best used with many
+	// steps, which means more load on the hub.
+	Dither bool
+}
+
+// Fade gradually changes the state of a light from one state to another. The
+// brightness, color temperature, and xy color are interpolated linearly. See
+// FadeWith for details.
+func (s *Session) Fade(ctx context.Context, id string, from, to LightState, d time.Duration, steps int) error {
+	return s.FadeWith(ctx, id, from, to, d, steps, FadeOptions{})
+}
+
+// FadeWith gradually changes the state of a light from one state to another
+// over a duration d. The from state is applied immediately, followed by steps
+// updates evenly spaced over d, the last of which applies the to state. If d
+// isn't positive, only the to state is applied. The number of steps is
+// reduced if necessary so that updates are at least MinCommandInterval apart.
+// Brightness is kept at or above the light's minimum stable brightness so the
+// light doesn't flicker at the low end of the fade. If the session checks
+// reachability, it's only checked before the fade starts. The fade stops early
+// if ctx is cancelled.
+func (s *Session) FadeWith(ctx context.Context, id string, from, to LightState, d time.Duration, steps int, opts FadeOptions) error {
+	light, err := s.GetLight(id)
+	if err != nil {
+		return err
+	}
+	if err = s.reachableError(map[string]Light{id: light}, id); err != nil {
+		return err
+	}
+	min := light.MinStableBrightness()
+
+	set := func(state LightState) error {
+		if state.Brightness != 0 && state.Brightness < min {
+			state.Brightness = min
+		}
+		_, err := s.setLightState(id, state, false)
+		return err
+	}
+	return fade(ctx, set, from, to, d, steps, MinCommandInterval, opts)
+}
+
+// FadeGroupWith gradually changes the state of all the lights in a group from
+// one state to another. It works like FadeWith, except that updates are at
+// least MinGroupCommandInterval apart.
+func (s *Session) FadeGroupWith(ctx context.Context, id string, from, to LightState, d time.Duration, steps int, opts FadeOptions) error {
+	set := func(state LightState) error {
+		return s.SetGroupState(id, state)
+	}
+	return fade(ctx, set, from, to, d, steps, MinGroupCommandInterval, opts)
+}
+
+// fade applies a series of states from one state to another using set, with
+// updates at least minInterval apart. If d isn't positive, the to state is
+// applied immediately.
+func fade(ctx context.Context, set func(LightState) error, from, to LightState, d time.Duration, steps int, minInterval time.Duration, opts FadeOptions) error {
+	if d <= 0 {
+		return set(to)
+	}
+
+	if max := int(d / minInterval); steps > max {
+		steps = max
+	}
+	if steps < 1 {
+		steps = 1
+	}
+
+	easing := opts.Easing
+	if easing == nil {
+		easing = Linear
+	}
+
+	interval := d / time.Duration(steps)
+	transition := int(interval / (100 * time.Millisecond))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// the difference between the exact and sent brightness so far, which is
+	// carried into the next step when dithering
+	var residual float64
+
+	for i := 0; i <= steps; i++ {
+		t := easing(float64(i) / float64(steps))
+		state := interpolateState(from, to, t, opts.Perceptual)
+		if opts.Dither && i > 0 && i < steps && from.Brightness != 0 && to.Brightness != 0 {
+			exact := interpolateBrightness(from.Brightness, to.Brightness, t, opts.Perceptual) + residual
+			state.Brightness = int(clamp(math.Round(exact), MinBrightness, MaxBrightness))
+			residual = exact - float64(state.Brightness)
+		}
+		switch i {
+		case 0:
+			state.On = from.On
+		case steps:
+			state.On = to.On
+		}
+		if i > 0 {
+			state.TransitionTime = &transition
+		}
+
+		if err := set(state); err != nil {
+			return err
+		}
+
+		if i < steps {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+
+	return nil
+}
+
+// interpolateState returns a state a fraction t of the way from one state to
+// another. Only the brightness, color temperature, and xy color are
+// interpolated, and only when they are set in both states; otherwise the value
+// from the to state is used. If perceptual is true, brightness is interpolated
+// in CIE L*.
+func interpolateState(from, to LightState, t float64, perceptual bool) (state LightState) {
+	if perceptual && from.Brightness != 0 && to.Brightness != 0 {
+		bri := interpolateBrightness(from.Brightness, to.Brightness, t, true)
+		state.Brightness = int(clamp(math.Round(bri), MinBrightness, MaxBrightness))
+	} else {
+		state.Brightness = interpolateInt(from.Brightness, to.Brightness, t)
+	}
+	state.Ct = interpolateInt(from.Ct, to.Ct, t)
+
+	if from.Xy != [2]float64{} && to.Xy != [2]float64{} {
+		state.Xy = [2]float64{
+			from.Xy[0] + (to.Xy[0]-from.Xy[0])*t,
+			from.Xy[1] + (to.Xy[1]-from.Xy[1])*t,
+		}
+	} else {
+		state.Xy = to.Xy
+	}
+
+	return
+}
+
+// interpolateBrightness returns the exact brightness a fraction t of the way
+// from one brightness to another, interpolating in CIE L* if perceptual is
+// true.
+func interpolateBrightness(from, to int, t float64, perceptual bool) float64 {
+	if perceptual {
+		return lightnessBri(interpolateFloat(briLightness(from), briLightness(to), t))
+	}
+	return interpolateFloat(float64(from), float64(to), t)
+}
+
+func interpolateFloat(from, to, t float64) float64 {
+	return from + (to-from)*t
+}
+
+// briLightness returns the CIE L* lightness, from 0 to 100, of a brightness
+// value, treating brightness as linear in luminance.
+func briLightness(bri int) float64 {
+	Y := float64(bri) / MaxBrightness
+	if Y > 216.0/24389.0 {
+		return 116*math.Cbrt(Y) - 16
+	}
+	return 24389.0 / 27.0 * Y
+}
+
+// lightnessBri returns the brightness value, unrounded, with a given CIE L*
+// lightness.
+func lightnessBri(l float64) float64 {
+	var Y float64
+	if l > 8 {
+		Y = math.Pow((l+16)/116, 3)
+	} else {
+		Y = l * 27.0 / 24389.0
+	}
+	return Y * MaxBrightness
+}
+
+// interpolateInt returns a value a fraction t of the way from one value to
+// another, or to if either value is unset (0).
+func interpolateInt(from, to int, t float64) int {
+	if from == 0 || to == 0 {
+		return to
+	}
+	return int(math.Round(float64(from) + float64(to-from)*t))
+}
@@ -0,0 +1,58 @@
+package hue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hue "github.com/jason0x43/go-hue"
+	"github.com/jason0x43/go-hue/hue/huetest"
+)
+
+func TestFadeWithoutDuration(t *testing.T) {
+	from := hue.LightState{On: hue.Bool(true), Brightness: 10, Ct: 400}
+	to := hue.LightState{On: hue.Bool(true), Brightness: 200, Ct: 250}
+
+	tests := []struct {
+		name    string
+		fade    func(s *hue.Session) error
+		wantBri int
+		wantOn  bool
+	}{
+		{"Fade", func(s *hue.Session) error {
+			return s.Fade(context.Background(), "1", from, to, 0, 10)
+		}, 200, true},
+		{"FadeWith negative", func(s *hue.Session) error {
+			return s.FadeWith(context.Background(), "1", from, to, -time.Second, 10, hue.FadeOptions{Dither: true})
+		}, 200, true},
+		{"FadeGroupWith", func(s *hue.Session) error {
+			return s.FadeGroupWith(context.Background(), "0", from, to, 0, 10, hue.FadeOptions{})
+		}, 200, true},
+		{"WakeUp", func(s *hue.Session) error {
+			return s.WakeUp(context.Background(), "0", 0)
+		}, hue.MaxBrightness, true},
+		{"Sleep", func(s *hue.Session) error {
+			return s.Sleep(context.Background(), "0", 0)
+		}, hue.MinBrightness, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bridge := huetest.NewMockBridge()
+			defer bridge.Close()
+			bridge.AddLight("1", "Lamp")
+			session := bridge.Session()
+
+			if err := test.fade(&session); err != nil {
+				t.Fatal(err)
+			}
+
+			bridge.Lock()
+			state := bridge.Lights["1"].State
+			bridge.Unlock()
+			if state.Brightness != test.wantBri || state.IsOn() != test.wantOn {
+				t.Errorf("light state = bri %d, on %v; want bri %d, on %v", state.Brightness, state.IsOn(), test.wantBri, test.wantOn)
+			}
+		})
+	}
+}
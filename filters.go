@@ -0,0 +1,85 @@
+package hue
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FilterLights returns the lights on the hub for which pred returns true,
+// sorted by ID.
+func (s *Session) FilterLights(pred func(Light) bool) ([]Light, error) {
+	lights, err := s.Lights()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Light
+	for _, light := range lights {
+		if pred(light) {
+			matches = append(matches, light)
+		}
+	}
+	sort.Sort(ByID(matches))
+	return matches, nil
+}
+
+// LightsByType returns the lights of a given type (e.g., "Extended color
+// light"), sorted by ID.
+func (s *Session) LightsByType(t string) ([]Light, error) {
+	return s.FilterLights(func(l Light) bool {
+		return l.Type == t
+	})
+}
+
+// LightsByRoom returns the lights in a room or other group, sorted by ID.
+func (s *Session) LightsByRoom(groupID string) ([]Light, error) {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.FilterLights(func(l Light) bool {
+		return contains(group.Lights, l.ID)
+	})
+}
+
+// OnLights returns the lights that are on, sorted by ID.
+func (s *Session) OnLights() ([]Light, error) {
+	return s.FilterLights(func(l Light) bool {
+		return l.State.IsOn()
+	})
+}
+
+// LightNameIndex returns the IDs of the lights on the hub keyed by their
+// names. Light names don't have to be unique, so an error naming the
+// duplicates is returned if two lights share a name.
+func (s *Session) LightNameIndex() (map[string]string, error) {
+	lights, err := s.Lights()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]string, len(lights))
+	for id, light := range lights {
+		if other, ok := index[light.Name]; ok {
+			ids := []string{other, id}
+			sort.Strings(ids)
+			return nil, fmt.Errorf("Lights %s and %s are both named '%s'", ids[0], ids[1], light.Name)
+		}
+		index[light.Name] = id
+	}
+	return index, nil
+}
+
+// LightNames returns the names of the lights on the hub keyed by their IDs.
+func (s *Session) LightNames() (map[string]string, error) {
+	lights, err := s.Lights()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(lights))
+	for id, light := range lights {
+		names[id] = light.Name
+	}
+	return names, nil
+}
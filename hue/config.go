@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// config holds the hub connection details saved between CLI runs.
+type config struct {
+	IPAddress string `json:"ipaddress"`
+	Username  string `json:"username"`
+}
+
+// configPath returns the path of the CLI's config file, which is in
+// $XDG_CONFIG_HOME/go-hue, or ~/.config/go-hue if XDG_CONFIG_HOME isn't set.
+func configPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "go-hue", "config.json"), nil
+}
+
+// loadConfig loads the CLI's config. If no config file exists, an empty config
+// is returned.
+func loadConfig() (cfg config, err error) {
+	path, err := configPath()
+	if err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &cfg)
+	return
+}
+
+// saveConfig saves the CLI's config, creating its directory if necessary.
+func saveConfig(cfg config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
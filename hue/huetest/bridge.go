@@ -0,0 +1,374 @@
+// Package huetest provides an in-memory mock Hue hub for testing code that
+// uses the hue package.
+//
+//	bridge := huetest.NewMockBridge()
+//	defer bridge.Close()
+//	session := bridge.Session()
+//	err := session.SetLightState("1", hue.LightState{On: hue.Bool(true)})
+package huetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	hue "github.com/jason0x43/go-hue"
+)
+
+// DefaultUsername is the username accepted by a new MockBridge.
+const DefaultUsername = "huetest"
+
+// MockBridge is a fake hub backed by an httptest.Server. It serves the config,
+// lights, groups, and scenes resources from in-memory state, and applies
+// config changes, light state updates, group actions, scene updates, and scene
+// recalls to that state. Group 0 contains all the lights.
+//
+// The state is exported so tests can set it up and make assertions about it.
+// Use Lock and Unlock around any access to the state while the bridge is
+// running.
+type MockBridge struct {
+	sync.Mutex
+
+	// Username is the username the bridge accepts. Requests with any other
+	// username fail with an unauthorized user error. New users created
+	// through the API are given this username.
+	Username string
+
+	Config hue.Config
+	Lights map[string]hue.Light
+	Groups map[string]hue.Group
+	Scenes map[string]hue.SceneDetail
+
+	server *httptest.Server
+}
+
+// NewMockBridge starts a new mock bridge with no lights, groups, or scenes.
+func NewMockBridge() *MockBridge {
+	b := &MockBridge{
+		Username: DefaultUsername,
+		Lights:   map[string]hue.Light{},
+		Groups:   map[string]hue.Group{},
+		Scenes:   map[string]hue.SceneDetail{},
+	}
+	b.Config.Name = "Mock Bridge"
+	b.Config.BridgeID = "001788FFFE000000"
+	b.Config.ModelID = hue.BridgeModelV2
+	b.Config.APIVersion = "1.50.0"
+	b.server = httptest.NewServer(http.HandlerFunc(b.serveHTTP))
+	return b
+}
+
+// Close shuts down the bridge.
+func (b *MockBridge) Close() {
+	b.server.Close()
+}
+
+// IPAddress returns the address of the bridge, which can be used with
+// hue.OpenSession.
+func (b *MockBridge) IPAddress() string {
+	return strings.TrimPrefix(b.server.URL, "http://")
+}
+
+// Session returns a session for the bridge's username.
+func (b *MockBridge) Session() hue.Session {
+	return hue.OpenSession(b.IPAddress(), b.Username)
+}
+
+// AddLight adds a light to the bridge with the given ID and name. The light is
+// a reachable extended color light that is off.
+func (b *MockBridge) AddLight(id string, name string) hue.Light {
+	b.Lock()
+	defer b.Unlock()
+
+	var light hue.Light
+	light.ID = id
+	light.Name = name
+	light.Type = "Extended color light"
+	light.Model = "LCT015"
+	light.UniqueID = fmt.Sprintf("00:17:88:01:00:00:00:%02x-0b", len(b.Lights)+1)
+	light.State = hue.LightState{
+		On:         hue.Bool(false),
+		Brightness: hue.MaxBrightness,
+		Ct:         366,
+		ColorMode:  hue.ColorModeCT,
+		Reachable:  true,
+	}
+	b.Lights[id] = light
+	return light
+}
+
+// support functions ///////////////////////////////////////////////////
+
+type apiError struct {
+	Type        int    `json:"type"`
+	Address     string `json:"address"`
+	Description string `json:"description"`
+}
+
+func (b *MockBridge) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	b.Lock()
+	defer b.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// path is "/api", "/api/<username>", or "/api/<username>/<resource>..."
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "api" {
+		http.NotFound(w, r)
+		return
+	}
+	parts = parts[1:]
+
+	if len(parts) == 0 && r.Method == "POST" {
+		writeJSON(w, []map[string]interface{}{{"success": map[string]string{
+			"username":  b.Username,
+			"clientkey": "00000000000000000000000000000000",
+		}}})
+		return
+	}
+
+	if len(parts) >= 1 && r.Method == "GET" && (parts[0] == "config" || (parts[0] == "0" && len(parts) == 2 && parts[1] == "config")) {
+		writeJSON(w, hue.PublicConfig{
+			Name:       b.Config.Name,
+			BridgeID:   b.Config.BridgeID,
+			ModelID:    b.Config.ModelID,
+			SwVersion:  b.Config.SwVersion,
+			APIVersion: b.Config.APIVersion,
+		})
+		return
+	}
+
+	if len(parts) == 0 || parts[0] != b.Username {
+		b.writeError(w, 1, "/", "unauthorized user")
+		return
+	}
+	resource := parts[1:]
+	address := "/" + strings.Join(resource, "/")
+
+	var body map[string]json.RawMessage
+	if r.Method == "PUT" || r.Method == "POST" {
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			b.writeError(w, 2, address, "body contains invalid json")
+			return
+		}
+	}
+
+	switch {
+	case r.Method == "GET" && len(resource) == 1 && resource[0] == "config":
+		writeJSON(w, b.Config)
+	case r.Method == "PUT" && len(resource) == 1 && resource[0] == "config":
+		merge(&b.Config, body)
+		b.writeSuccesses(w, address, body)
+	case r.Method == "GET" && len(resource) == 1 && resource[0] == "lights":
+		writeJSON(w, b.Lights)
+	case r.Method == "GET" && len(resource) == 2 && resource[0] == "lights":
+		if light, ok := b.Lights[resource[1]]; ok {
+			writeJSON(w, light)
+			return
+		}
+		b.writeNotAvailable(w, address)
+	case r.Method == "PUT" && len(resource) == 3 && resource[0] == "lights" && resource[2] == "state":
+		if _, ok := b.Lights[resource[1]]; !ok {
+			b.writeNotAvailable(w, address)
+			return
+		}
+		b.applyLightState(resource[1], body)
+		b.writeSuccesses(w, address, body)
+	case r.Method == "GET" && len(resource) == 1 && resource[0] == "groups":
+		writeJSON(w, b.Groups)
+	case r.Method == "GET" && len(resource) == 2 && resource[0] == "groups":
+		if resource[1] == "0" {
+			writeJSON(w, b.allLightsGroup())
+			return
+		}
+		if group, ok := b.Groups[resource[1]]; ok {
+			writeJSON(w, group)
+			return
+		}
+		b.writeNotAvailable(w, address)
+	case r.Method == "PUT" && len(resource) == 3 && resource[0] == "groups" && resource[2] == "action":
+		if !b.applyGroupAction(resource[1], body) {
+			b.writeNotAvailable(w, address)
+			return
+		}
+		b.writeSuccesses(w, address, body)
+	case r.Method == "GET" && len(resource) == 1 && resource[0] == "scenes":
+		writeJSON(w, b.Scenes)
+	case r.Method == "GET" && len(resource) == 2 && resource[0] == "scenes":
+		if scene, ok := b.Scenes[resource[1]]; ok {
+			writeJSON(w, scene)
+			return
+		}
+		b.writeNotAvailable(w, address)
+	case r.Method == "PUT" && len(resource) == 2 && resource[0] == "scenes":
+		if !b.updateScene(resource[1], body) {
+			b.writeNotAvailable(w, address)
+			return
+		}
+		b.writeSuccesses(w, address, body)
+	case r.Method == "PUT" && len(resource) == 4 && resource[0] == "scenes" && resource[2] == "lightstates":
+		scene, ok := b.Scenes[resource[1]]
+		if !ok {
+			b.writeNotAvailable(w, address)
+			return
+		}
+		if scene.LightStates == nil {
+			scene.LightStates = map[string]hue.LightState{}
+		}
+		scene.LightStates[resource[3]] = mergeState(scene.LightStates[resource[3]], body)
+		b.Scenes[resource[1]] = scene
+		b.writeSuccesses(w, address, body)
+	default:
+		b.writeError(w, 4, address, fmt.Sprintf("method, %s, not available for resource, %s", r.Method, address))
+	}
+}
+
+// allLightsGroup returns group 0, the special group that contains every light.
+func (b *MockBridge) allLightsGroup() hue.Group {
+	var group hue.Group
+	group.ID = "0"
+	group.Name = "Group 0"
+	group.Type = "LightGroup"
+	group.Lights = []string{}
+	for id := range b.Lights {
+		group.Lights = append(group.Lights, id)
+	}
+	sort.Strings(group.Lights)
+	return group
+}
+
+// updateScene applies an update to a scene's attributes, returning false if
+// the scene doesn't exist. If the update sets storelightstate, the current
+// states of the scene's lights are stored in the scene.
+func (b *MockBridge) updateScene(id string, body map[string]json.RawMessage) bool {
+	scene, ok := b.Scenes[id]
+	if !ok {
+		return false
+	}
+	merge(&scene, body)
+
+	var store bool
+	if raw, ok := body["storelightstate"]; ok {
+		json.Unmarshal(raw, &store)
+	}
+	if store {
+		scene.LightStates = map[string]hue.LightState{}
+		for _, lightID := range scene.Lights {
+			if light, ok := b.Lights[lightID]; ok {
+				state := light.State
+				state.ColorMode = ""
+				state.Reachable = false
+				scene.LightStates[lightID] = state
+			}
+		}
+	}
+
+	b.Scenes[id] = scene
+	return true
+}
+
+// applyLightState merges a state update into a light's state.
+func (b *MockBridge) applyLightState(id string, body map[string]json.RawMessage) {
+	light := b.Lights[id]
+	light.State = mergeState(light.State, body)
+	b.Lights[id] = light
+}
+
+// applyGroupAction applies an action to a group and its lights, returning
+// false if the group doesn't exist.
+func (b *MockBridge) applyGroupAction(id string, body map[string]json.RawMessage) bool {
+	var lights []string
+	if id == "0" {
+		for lightID := range b.Lights {
+			lights = append(lights, lightID)
+		}
+	} else {
+		group, ok := b.Groups[id]
+		if !ok {
+			return false
+		}
+		lights = group.Lights
+	}
+
+	state := map[string]json.RawMessage{}
+	for key, value := range body {
+		if key != "scene" {
+			state[key] = value
+		}
+	}
+
+	if raw, ok := body["scene"]; ok {
+		var sceneID string
+		json.Unmarshal(raw, &sceneID)
+		for lightID, state := range b.Scenes[sceneID].LightStates {
+			data, _ := json.Marshal(state)
+			var stateBody map[string]json.RawMessage
+			json.Unmarshal(data, &stateBody)
+			b.applyLightState(lightID, stateBody)
+		}
+	}
+
+	for _, lightID := range lights {
+		if _, ok := b.Lights[lightID]; ok {
+			b.applyLightState(lightID, state)
+		}
+	}
+
+	if group, ok := b.Groups[id]; ok {
+		group.State = mergeState(group.State, state)
+		b.Groups[id] = group
+	}
+	return true
+}
+
+// mergeState returns a state with the values in a JSON state update applied,
+// and with the color mode updated to match the color values that were set.
+func mergeState(state hue.LightState, body map[string]json.RawMessage) hue.LightState {
+	merge(&state, body)
+	state.TransitionTime = nil
+
+	switch {
+	case body["xy"] != nil:
+		state.ColorMode = hue.ColorModeXY
+	case body["ct"] != nil:
+		state.ColorMode = hue.ColorModeCT
+	case body["hue"] != nil || body["sat"] != nil:
+		state.ColorMode = hue.ColorModeHS
+	}
+	return state
+}
+
+// writeSuccesses writes a success message for each value in a request body.
+func (b *MockBridge) writeSuccesses(w http.ResponseWriter, address string, body map[string]json.RawMessage) {
+	messages := []map[string]interface{}{}
+	for key, value := range body {
+		messages = append(messages, map[string]interface{}{
+			"success": map[string]json.RawMessage{address + "/" + key: value},
+		})
+	}
+	writeJSON(w, messages)
+}
+
+func (b *MockBridge) writeError(w http.ResponseWriter, errorType int, address string, description string) {
+	writeJSON(w, []map[string]apiError{{"error": {errorType, address, description}}})
+}
+
+func (b *MockBridge) writeNotAvailable(w http.ResponseWriter, address string) {
+	b.writeError(w, 3, address, fmt.Sprintf("resource, %s, not available", address))
+}
+
+// merge applies the values in a JSON update to a value.
+func merge(value interface{}, body map[string]json.RawMessage) {
+	data, _ := json.Marshal(body)
+	json.Unmarshal(data, value)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,95 @@
+package huetest
+
+import (
+	"testing"
+
+	hue "github.com/jason0x43/go-hue"
+)
+
+func TestIsAuthorized(t *testing.T) {
+	bridge := NewMockBridge()
+	defer bridge.Close()
+
+	session := bridge.Session()
+	if ok, err := session.IsAuthorized(); err != nil || !ok {
+		t.Errorf("IsAuthorized() = %v, %v; want true, nil", ok, err)
+	}
+
+	session = hue.OpenSession(bridge.IPAddress(), "someone-else")
+	if ok, err := session.IsAuthorized(); err != nil || ok {
+		t.Errorf("IsAuthorized() with unknown user = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestGroupZero(t *testing.T) {
+	bridge := NewMockBridge()
+	defer bridge.Close()
+	bridge.AddLight("2", "Lamp")
+	bridge.AddLight("1", "Ceiling")
+
+	session := bridge.Session()
+	group, err := session.GetGroup("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Lights) != 2 || group.Lights[0] != "1" || group.Lights[1] != "2" {
+		t.Errorf("group 0 lights = %v, want [1 2]", group.Lights)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	bridge := NewMockBridge()
+	defer bridge.Close()
+
+	session := bridge.Session()
+	if err := session.SetBridgeName("Living Room"); err != nil {
+		t.Fatal(err)
+	}
+
+	bridge.Lock()
+	name := bridge.Config.Name
+	bridge.Unlock()
+	if name != "Living Room" {
+		t.Errorf("Config.Name = %q, want %q", name, "Living Room")
+	}
+}
+
+func TestUpdateScene(t *testing.T) {
+	bridge := NewMockBridge()
+	defer bridge.Close()
+	bridge.AddLight("1", "Lamp")
+
+	var scene hue.SceneDetail
+	scene.ID = "abc"
+	scene.Name = "Evening"
+	scene.Lights = []string{"1"}
+	scene.Version = hue.SceneVersion2
+	bridge.Lock()
+	bridge.Scenes["abc"] = scene
+	bridge.Unlock()
+
+	session := bridge.Session()
+	if err := session.SetSceneName("abc", "Night"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.SetLightState("1", hue.LightState{On: hue.Bool(true), Brightness: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.StoreCurrentInScene("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UpdateSceneLightState("abc", "1", hue.LightState{Brightness: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	bridge.Lock()
+	scene = bridge.Scenes["abc"]
+	bridge.Unlock()
+	if scene.Name != "Night" {
+		t.Errorf("scene name = %q, want %q", scene.Name, "Night")
+	}
+	state := scene.LightStates["1"]
+	if state.On == nil || !*state.On || state.Brightness != 50 {
+		t.Errorf("scene state = %+v, want on with brightness 50", state)
+	}
+}
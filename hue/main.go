@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -13,46 +14,135 @@ import (
 func main() {
 	var username string
 	var newSession bool
+	var jsonOutput bool
 
-	flag.StringVar(&username, "user", "", "hub username")
+	flag.StringVar(&username, "user", "", "hub username (saved for later runs)")
 	flag.BoolVar(&newSession, "new", false, "create new user?")
+	flag.BoolVar(&jsonOutput, "json", false, "output JSON?")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [command]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  on <id>           turn a light on\n")
+		fmt.Fprintf(os.Stderr, "  off <id>          turn a light off\n")
+		fmt.Fprintf(os.Stderr, "  color <id> <hex>  set a light's color\n")
+		fmt.Fprintf(os.Stderr, "  scene <name>      recall a scene\n\n")
+		fmt.Fprintf(os.Stderr, "With no command, hubs, lights, and scenes are listed.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
 	var hubs []hue.Hub
-	var err error
-	if hubs, err = hue.GetHubs(); err != nil {
-		log.Fatal("error: %s", err)
+	var session hue.Session
+	if username == "" && !newSession && cfg.Username != "" {
+		session = hue.OpenSession(cfg.IPAddress, cfg.Username)
+	} else {
+		if hubs, err = hue.GetHubs(); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		if len(hubs) == 0 {
+			log.Fatalf("error: no hubs found")
+		}
+
+		if username == "" || newSession {
+			log.Printf("Press the Connect button on your hub, then press enter to continue...")
+			bio := bufio.NewReader(os.Stdin)
+			bio.ReadLine()
+			if session, err = hue.NewSession(hubs[0].IPAddress); err != nil {
+				log.Fatalf("error: %s", err)
+			}
+			log.Printf("Created user %s", session.Username())
+		} else {
+			session = hue.OpenSession(hubs[0].IPAddress, username)
+		}
 
+		cfg = config{IPAddress: session.IPAddress(), Username: session.Username()}
+		if err = saveConfig(cfg); err != nil {
+			log.Printf("Unable to save config: %s", err)
+		}
 	}
+
+	if flag.NArg() > 0 {
+		if err = runCommand(&session, flag.Args()); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	}
+
+	lights, _ := session.Lights()
+	scenes, _ := session.Scenes()
+
+	if jsonOutput {
+		printJSON(hubs, lights, scenes)
+		return
+	}
+
+	if hubs != nil {
+		printHubs(hubs)
+	}
+	printLights(lights)
+	printScenes(scenes)
+}
+
+func runCommand(session *hue.Session, args []string) error {
+	command, args := args[0], args[1:]
+
+	switch {
+	case command == "on" && len(args) == 1:
+		return session.SetLightState(args[0], hue.LightState{On: hue.Bool(true)})
+
+	case command == "off" && len(args) == 1:
+		return session.SetLightState(args[0], hue.LightState{On: hue.Bool(false)})
+
+	case command == "color" && len(args) == 2:
+		light, err := session.GetLight(args[0])
+		if err != nil {
+			return err
+		}
+		if err = light.SetColorHex(args[1]); err != nil {
+			return err
+		}
+		return session.SetLightState(args[0], hue.LightState{
+			On:         hue.Bool(true),
+			Xy:         light.State.Xy,
+			Brightness: light.State.Brightness,
+			Effect:     light.State.Effect,
+		})
+
+	case command == "scene" && len(args) == 1:
+		return session.SetSceneByName(args[0])
+	}
+
+	flag.Usage()
+	os.Exit(2)
+	return nil
+}
+
+func printHubs(hubs []hue.Hub) {
 	fmt.Printf("Hubs\n")
 	fmt.Printf("----\n")
 	for _, h := range hubs {
 		fmt.Printf("%s\n", h)
 	}
 	fmt.Printf("\n")
+}
 
-	var session hue.Session
-	if newSession {
-		log.Printf("Press the Connect button on your hub, then press enter to continue...")
-		bio := bufio.NewReader(os.Stdin)
-		bio.ReadLine()
-		if session, err = hue.NewSession(hubs[0].IpAddress, username); err != nil {
-			log.Fatal("error: %s", err)
-		}
-	} else {
-		session = hue.OpenSession(hubs[0].IpAddress, username)
-	}
-
-	lights, _ := session.Lights()
+func printLights(lights map[string]hue.Light) {
 	fmt.Printf("Lights\n")
 	fmt.Printf("------\n")
 	for _, l := range lights {
-		fmt.Printf("%s\n", l)
+		fmt.Printf("%s\n", &l)
 	}
 	fmt.Printf("\n")
+}
 
-	scenes, _ := session.Scenes()
+func printScenes(scenes map[string]hue.Scene) {
 	fmt.Printf("Scenes\n")
 	fmt.Printf("------\n")
 	for _, s := range scenes {
@@ -60,3 +150,17 @@ func main() {
 	}
 	fmt.Printf("\n")
 }
+
+func printJSON(hubs []hue.Hub, lights map[string]hue.Light, scenes map[string]hue.Scene) {
+	data := struct {
+		Hubs   []hue.Hub            `json:"hubs"`
+		Lights map[string]hue.Light `json:"lights"`
+		Scenes map[string]hue.Scene `json:"scenes"`
+	}{hubs, lights, scenes}
+
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+	fmt.Printf("%s\n", out)
+}
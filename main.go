@@ -6,16 +6,23 @@ package hue
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Hub represents a Hue hub.
@@ -30,9 +37,11 @@ func (h Hub) String() string {
 	return h.IPAddress
 }
 
-// LightState describes the state of a light.
+// LightState describes the state of a light. When used to update a light, only
+// the fields that are set are sent to the hub; On is a pointer so that an
+// update can leave a light's on state unchanged.
 type LightState struct {
-	On         bool       `json:"on"`
+	On         *bool      `json:"on,omitempty"`
 	Brightness int        `json:"bri,omitempty"`
 	Hue        int        `json:"hue,omitempty"`
 	Saturation int        `json:"sat,omitempty"`
@@ -40,42 +49,431 @@ type LightState struct {
 	Ct         int        `json:"ct,omitempty"`
 	Alert      string     `json:"alert,omitempty"`
 	Effect     string     `json:"effect,omitempty"`
-	ColorMode  string     `json:"colormode,omitempty"`
+	ColorMode  ColorMode  `json:"colormode,omitempty"`
+
+	// Reachable is reported by lights and is false when the hub can't
+	// communicate with a light, such as when it's switched off at the wall.
+	// It is read-only, so it's never sent to the hub.
+	Reachable bool `json:"reachable,omitempty"`
+
+	// TransitionTime is the duration of a state change in multiples of
+	// 100ms. It is only used when updating a light.
+	TransitionTime *int `json:"transitiontime,omitempty"`
+}
+
+// MarshalJSON encodes a LightState, omitting Xy when it isn't set.
+func (s LightState) MarshalJSON() ([]byte, error) {
+	type state LightState
+	var xy *[2]float64
+	if s.Xy != [2]float64{} {
+		xy = &s.Xy
+	}
+	return json.Marshal(struct {
+		state
+		Xy *[2]float64 `json:"xy,omitempty"`
+	}{state(s), xy})
+}
+
+// Limits on hue and saturation values accepted by the hub.
+const (
+	MaxHue        = 65535
+	MaxSaturation = 254
+)
+
+// sendableHue returns a hue that won't be omitted from an update. Unset
+// fields are omitted, so a hue of 0 is sent as MaxHue, which is the same
+// color.
+func sendableHue(hue int) int {
+	if hue == 0 {
+		return MaxHue
+	}
+	return hue
+}
+
+// sendableSat returns a saturation that won't be omitted from an update.
+// Unset fields are omitted, so a saturation of 0 is sent as 1.
+func sendableSat(sat int) int {
+	if sat == 0 {
+		return 1
+	}
+	return sat
+}
+
+// Validate returns an error naming the first field of a state that is set to
+// a value outside the range accepted by the hub. The color temperature is
+// checked against the widest range the hub accepts; use ValidateFor to check
+// it against the range a particular light supports.
+func (s LightState) Validate() error {
+	return s.validate(MinCt, MaxCt)
+}
+
+// ValidateFor works like Validate, but checks the color temperature against
+// the range the light supports (see Light.CtRange).
+func (s LightState) ValidateFor(light *Light) error {
+	return s.validate(light.CtRange())
+}
+
+// validate checks a state, allowing color temperatures from minCt to maxCt.
+func (s LightState) validate(minCt, maxCt int) error {
+	check := func(field string, value, min, max int) error {
+		if value < min || value > max {
+			return fmt.Errorf("Invalid %s %d, must be %d to %d", field, value, min, max)
+		}
+		return nil
+	}
+
+	if s.Brightness != 0 {
+		if err := check("bri", s.Brightness, MinBrightness, MaxBrightness); err != nil {
+			return err
+		}
+	}
+	if err := check("hue", s.Hue, 0, MaxHue); err != nil {
+		return err
+	}
+	if err := check("sat", s.Saturation, 0, MaxSaturation); err != nil {
+		return err
+	}
+	if s.Ct != 0 {
+		if err := check("ct", s.Ct, minCt, maxCt); err != nil {
+			return err
+		}
+	}
+	for _, v := range s.Xy {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("Invalid xy %v, values must be 0 to 1", s.Xy)
+		}
+	}
+	return nil
+}
+
+// Clamped returns a copy of a state with all its fields clamped to the ranges
+// accepted by the hub.
+func (s LightState) Clamped() LightState {
+	return s.clamped(MinCt, MaxCt)
+}
+
+// ClampedFor works like Clamped, but clamps the color temperature to the range
+// the light supports (see Light.CtRange).
+func (s LightState) ClampedFor(light *Light) LightState {
+	return s.clamped(light.CtRange())
+}
+
+// clamped clamps a state, with color temperatures clamped to [minCt, maxCt].
+func (s LightState) clamped(minCt, maxCt int) LightState {
+	clampInt := func(value, min, max int) int {
+		return int(clamp(float64(value), float64(min), float64(max)))
+	}
+
+	if s.Brightness != 0 {
+		s.Brightness = clampInt(s.Brightness, MinBrightness, MaxBrightness)
+	}
+	s.Hue = clampInt(s.Hue, 0, MaxHue)
+	s.Saturation = clampInt(s.Saturation, 0, MaxSaturation)
+	if s.Ct != 0 {
+		s.Ct = clampInt(s.Ct, minCt, maxCt)
+	}
+	s.Xy = [2]float64{clamp(s.Xy[0], 0, 1), clamp(s.Xy[1], 0, 1)}
+	return s
+}
+
+// ColorMode is the way a light's color is currently set.
+type ColorMode string
+
+// Color modes reported by lights.
+const (
+	ColorModeHS ColorMode = "hs"
+	ColorModeXY ColorMode = "xy"
+	ColorModeCT ColorMode = "ct"
+)
+
+// IsOn returns true if the state has On set to true.
+func (s LightState) IsOn() bool {
+	return s.On != nil && *s.On
+}
+
+// Equal returns true if two states are the same, treating xy values that
+// differ by no more than epsilon as equal.
+func (s LightState) Equal(other LightState, epsilon float64) bool {
+	return equalBoolPtr(s.On, other.On) &&
+		s.Brightness == other.Brightness &&
+		s.Hue == other.Hue &&
+		s.Saturation == other.Saturation &&
+		math.Abs(s.Xy[0]-other.Xy[0]) <= epsilon &&
+		math.Abs(s.Xy[1]-other.Xy[1]) <= epsilon &&
+		s.Ct == other.Ct &&
+		s.Alert == other.Alert &&
+		s.Effect == other.Effect &&
+		s.ColorMode == other.ColorMode &&
+		equalIntPtr(s.TransitionTime, other.TransitionTime)
+}
+
+// Clone returns a deep copy of a state.
+func (s LightState) Clone() LightState {
+	if s.On != nil {
+		s.On = Bool(*s.On)
+	}
+	if s.TransitionTime != nil {
+		t := *s.TransitionTime
+		s.TransitionTime = &t
+	}
+	return s
+}
+
+func equalBoolPtr(a, b *bool) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
+func equalIntPtr(a, b *int) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
+// Bool returns a pointer to b, for use with LightState.On and SensorConfig.On.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Int returns a pointer to i, for use with SensorConfig.Sensitivity.
+func Int(i int) *int {
+	return &i
 }
 
 // Light represents a light.
 type Light struct {
 	hueLight
-	ID string
+	ID string `json:"id"`
 }
 
 type hueLight struct {
-	State     LightState `json:"state"`
-	Type      string     `json:"type"`
-	Name      string     `json:"name"`
-	Model     string     `json:"modelid"`
-	SwVersion string     `json:"swversion"`
+	State     LightState  `json:"state"`
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Model     string      `json:"modelid"`
+	SwVersion string      `json:"swversion"`
+	UniqueID  string      `json:"uniqueid"`
+	Config    LightConfig `json:"config"`
+	Caps      struct {
+		Control Capabilities `json:"control"`
+	} `json:"capabilities"`
 }
 
+// Capabilities describes the range of values a light supports.
+type Capabilities struct {
+	MinDimLevel    int          `json:"mindimlevel"`
+	MaxLumen       int          `json:"maxlumen"`
+	ColorGamutType string       `json:"colorgamuttype"`
+	ColorGamut     [][2]float64 `json:"colorgamut"`
+	Ct             struct {
+		Min int `json:"min"`
+		Max int `json:"max"`
+	} `json:"ct"`
+}
+
+// Limits on light state values accepted by the hub. Lights may report a
+// narrower color temperature range in their capabilities.
+const (
+	MinBrightness = 1
+	MaxBrightness = 254
+	MinCt         = 153
+	MaxCt         = 500
+)
+
 func (l *Light) String() string {
 	return fmt.Sprintf("[%s] %v", l.ID, l.Name)
 }
 
-// GetColorRGB returns a light's color as an RGB value
+// Clone returns a deep copy of a light, which can be modified without
+// affecting the original.
+func (l *Light) Clone() Light {
+	clone := *l
+	clone.State = l.State.Clone()
+	if l.Caps.Control.ColorGamut != nil {
+		clone.Caps.Control.ColorGamut = append([][2]float64(nil), l.Caps.Control.ColorGamut...)
+	}
+	return clone
+}
+
+// Capabilities returns the range of values a light supports.
+func (l *Light) Capabilities() Capabilities {
+	return l.Caps.Control
+}
+
+// Gamut returns the color gamut of a light. The gamut reported in the light's
+// capabilities is used if there is one; otherwise the gamut is chosen based on
+// the light's model.
+func (l *Light) Gamut() Gamut {
+	if gamut, err := NewGamutFromPoints(l.Caps.Control.ColorGamut); err == nil {
+		return gamut
+	}
+	return GetGamut(l.Model)
+}
+
+// CtRange returns the range of color temperatures, in mireds, supported by a
+// light. If the light doesn't report a range, MinCt and MaxCt are returned.
+func (l *Light) CtRange() (min, max int) {
+	ct := l.Caps.Control.Ct
+	if ct.Min == 0 || ct.Max == 0 {
+		return MinCt, MaxCt
+	}
+	return ct.Min, ct.Max
+}
+
+// SetColorTemperatureKelvin sets a light's color temperature in degrees
+// Kelvin, clamped to the range the light supports.
+func (l *Light) SetColorTemperatureKelvin(kelvin int) (err error) {
+	if kelvin <= 0 {
+		return fmt.Errorf("Invalid color temperature %d", kelvin)
+	}
+	min, max := l.CtRange()
+	ct := int(math.Round(1000000.0 / float64(kelvin)))
+	l.State.Ct = int(clamp(float64(ct), float64(min), float64(max)))
+	// xy takes precedence over ct, so clear it
+	l.State.Xy = [2]float64{}
+	l.State.ColorMode = ColorModeCT
+	l.State.Effect = EffectNone
+	log.Printf("%dK -> %d mireds", kelvin, l.State.Ct)
+	return
+}
+
+// SetBrightness sets a light's brightness, clamped to [MinBrightness,
+// MaxBrightness].
+func (l *Light) SetBrightness(bri int) {
+	l.State.Brightness = int(clamp(float64(bri), MinBrightness, MaxBrightness))
+}
+
+// MinStableBrightness returns the lowest brightness at which a light can
+// reliably stay lit, based on the minimum dim level (in 1/10000 lumen) and
+// maximum output reported in its capabilities. Some lights flicker or turn off
+// below this level. If the light doesn't report its capabilities,
+// MinBrightness is returned.
+func (l *Light) MinStableBrightness() int {
+	caps := l.Caps.Control
+	if caps.MinDimLevel <= 0 || caps.MaxLumen <= 0 {
+		return MinBrightness
+	}
+	fraction := float64(caps.MinDimLevel) / 10000.0 / float64(caps.MaxLumen)
+	return int(clamp(math.Ceil(fraction*MaxBrightness), MinBrightness, MaxBrightness))
+}
+
+// BrightnessPercent returns a light's brightness as a percentage, where 1% is
+// the light's minimum stable brightness and 100% is the maximum. A light that
+// is off is at 0%.
+func (l *Light) BrightnessPercent() int {
+	if !l.State.IsOn() || l.State.Brightness < MinBrightness {
+		return 0
+	}
+	min := l.MinStableBrightness()
+	if min >= MaxBrightness || l.State.Brightness <= min {
+		return 1
+	}
+	p := 1 + float64(l.State.Brightness-min)*99.0/float64(MaxBrightness-min)
+	return int(math.Round(p))
+}
+
+// SetBrightnessPercent sets a light's brightness from a percentage, where 1%
+// is the light's minimum stable brightness (see MinStableBrightness) and 100%
+// is the maximum. A percentage of 0 turns the light off.
+func (l *Light) SetBrightnessPercent(p int) {
+	if p <= 0 {
+		l.State.On = Bool(false)
+		return
+	}
+	if p > 100 {
+		p = 100
+	}
+	l.State.On = Bool(true)
+	min := l.MinStableBrightness()
+	bri := float64(min) + float64(p-1)*float64(MaxBrightness-min)/99.0
+	l.State.Brightness = int(math.Round(bri))
+}
+
+// ColorMode returns the way a light's color is currently set, which
+// determines which of its state fields describe its color.
+func (l *Light) ColorMode() ColorMode {
+	return l.State.ColorMode
+}
+
+// SupportedColorModes returns the color modes a light supports, based on its
+// type. Lights that only support brightness, or only on and off, support no
+// color modes.
+func (l *Light) SupportedColorModes() []ColorMode {
+	switch strings.ToLower(l.Type) {
+	case "extended color light":
+		return []ColorMode{ColorModeXY, ColorModeCT, ColorModeHS}
+	case "color light":
+		return []ColorMode{ColorModeXY, ColorModeHS}
+	case "color temperature light":
+		return []ColorMode{ColorModeCT}
+	}
+	return nil
+}
+
+// GetColorRGB returns a light's color as an RGB value, using the state fields
+// for the light's color mode.
 func (l *Light) GetColorRGB() (uint8, uint8, uint8) {
-	gamut := GetGamut(l.Model)
-	state := l.State
-	r, g, b := gamut.ToRGB(state.Xy[0], state.Xy[1], float64(state.Brightness)/255.0)
-	log.Printf("XyY(%f, %f, %f) -> RGB(%d, %d, %d)", state.Xy[0], state.Xy[1], float64(state.Brightness)/255.0, r, g, b)
+	return stateToRGB(l.Gamut(), l.State)
+}
+
+// stateToRGB returns the color of a light state as an RGB value.
+func stateToRGB(gamut Gamut, state LightState) (uint8, uint8, uint8) {
+	if state.ColorMode == ColorModeHS {
+		return stateHSVToRGB(state)
+	}
+
+	x, y := stateXy(state)
+	r, g, b := gamut.ToRGB(x, y, float64(state.Brightness)/255.0)
+	log.Printf("XyY(%f, %f, %f) -> RGB(%d, %d, %d)", x, y, float64(state.Brightness)/255.0, r, g, b)
 	return r, g, b
 }
 
-// SetColorRGB sets a light's color from an RGB value
+// stateXy returns the color of a light state as a point in the CIE xy color
+// space. States without an xy color, such as those of color temperature
+// lights, are converted from their color temperature, or are white if they
+// don't have one either.
+func stateXy(state LightState) (x, y float64) {
+	switch state.ColorMode {
+	case ColorModeCT:
+		return ctToXy(state.Ct)
+	case ColorModeHS:
+		r, g, b := stateHSVToRGB(state)
+		x, y, _ = gamutD.ToXyY(int(r), int(g), int(b))
+		return
+	}
+	if state.Xy == [2]float64{} {
+		if state.Ct != 0 {
+			return ctToXy(state.Ct)
+		}
+		return whitePoint.x, whitePoint.y
+	}
+	return state.Xy[0], state.Xy[1]
+}
+
+// stateHSVToRGB returns the color of a light state's hue, saturation, and
+// brightness as an RGB value.
+func stateHSVToRGB(state LightState) (uint8, uint8, uint8) {
+	h := float64(state.Hue) / MaxHue * 360.0
+	s := float64(state.Saturation) / MaxSaturation
+	v := float64(state.Brightness) / MaxBrightness
+	return hsvToRgb(h, s, v)
+}
+
+// SetColorRGB sets a light's color from an RGB value. The brightness is
+// derived from the RGB value.
 func (l *Light) SetColorRGB(r, g, b int) (err error) {
-	gamut := GetGamut(l.Model)
+	gamut := l.Gamut()
+	_, _, Y := gamut.ToXyY(r, g, b)
+	return l.SetColorRGBBrightness(r, g, b, int(math.Round(Y*255.0)))
+}
+
+// SetColorRGBBrightness sets a light's color from an RGB value, which is only
+// used for the color's hue and saturation, and its brightness separately from
+// bri, which is clamped to [MinBrightness, MaxBrightness].
+func (l *Light) SetColorRGBBrightness(r, g, b, bri int) (err error) {
+	gamut := l.Gamut()
 	x, y, Y := gamut.ToXyY(r, g, b)
 	l.State.Xy = [2]float64{x, y}
-	l.State.Brightness = int(math.Ceil(Y*255.0 - 0.5))
+	l.State.ColorMode = ColorModeXY
+	l.SetBrightness(bri)
+	l.State.Effect = EffectNone
 	log.Printf("RGB(%d, %d, %d) -> XyY(%f, %f, %f) [%d]", r, g, b, x, y, Y, l.State.Brightness)
 	return
 }
@@ -97,11 +495,64 @@ func (l *Light) SetColorHex(hex string) (err error) {
 	return l.SetColorRGB(int(r), int(g), int(b))
 }
 
-// GetColorHSL returns a light's color as an HSL value
+// GetColorHSL returns a light's color as an HSL value, using the state fields
+// for the light's color mode.
 func (l *Light) GetColorHSL() (float64, float64, float64) {
-	gamut := GetGamut(l.Model)
-	state := l.State
-	return gamut.ToHSL(state.Xy[0], state.Xy[1], float64(state.Brightness)/255.0)
+	return rgbToHsl(l.GetColorRGB())
+}
+
+// GetColorHSV returns a light's color as an HSV value, where H is in [0, 360],
+// S is in [0, 1], and V is in [0, 1].
+func (l *Light) GetColorHSV() (float64, float64, float64) {
+	if l.State.ColorMode == ColorModeHS {
+		return float64(l.State.Hue) / MaxHue * 360.0,
+			float64(l.State.Saturation) / MaxSaturation,
+			float64(l.State.Brightness) / MaxBrightness
+	}
+	return rgbToHsv(l.GetColorRGB())
+}
+
+// SetColorHSV sets a light's color from an HSV value, where H is in [0, 360],
+// S is in [0, 1], and V is in [0, 1]. Unlike HSL, where full lightness is
+// white, the value maps directly to the light's brightness. Like
+// StateBuilder.Hue and StateBuilder.Sat, a hue of 0 is set as MaxHue and a
+// saturation of 0 as 1, so they aren't omitted when the state is sent.
+func (l *Light) SetColorHSV(h, s, v float64) (err error) {
+	if s < 0 || s > 1 || v < 0 || v > 1 {
+		return fmt.Errorf("Invalid HSV value (%f, %f, %f)", h, s, v)
+	}
+
+	h = math.Mod(h, 360.0)
+	if h < 0 {
+		h += 360.0
+	}
+
+	l.State.Hue = sendableHue(int(math.Round(h / 360.0 * MaxHue)))
+	l.State.Saturation = sendableSat(int(math.Round(s * MaxSaturation)))
+	l.SetBrightness(int(math.Round(v * MaxBrightness)))
+	l.State.Xy = [2]float64{}
+	l.State.Ct = 0
+	l.State.ColorMode = ColorModeHS
+	l.State.Effect = EffectNone
+	return
+}
+
+// Complementary returns the color opposite a light's current color on the
+// color wheel.
+func (l *Light) Complementary() (uint8, uint8, uint8) {
+	return Complementary(l.GetColorRGB())
+}
+
+// Analogous returns n colors near a light's current color on the color wheel.
+func (l *Light) Analogous(n int) [][3]uint8 {
+	r, g, b := l.GetColorRGB()
+	return Analogous(r, g, b, n)
+}
+
+// Triadic returns the two colors that form an evenly spaced triad with a
+// light's current color.
+func (l *Light) Triadic() [2][3]uint8 {
+	return Triadic(l.GetColorRGB())
 }
 
 // SetColorHSL sets a light's color from an HSL value
@@ -109,6 +560,54 @@ func (l *Light) SetColorHSL(h, s, bri float64) (err error) {
 	return
 }
 
+// hueNames maps the upper bound of a range of hue angles to a color name.
+var hueNames = []struct {
+	max  float64
+	name string
+}{
+	{15, "red"},
+	{45, "orange"},
+	{70, "yellow"},
+	{150, "green"},
+	{190, "teal"},
+	{250, "blue"},
+	{290, "purple"},
+	{330, "pink"},
+	{360, "red"},
+}
+
+// ColorName returns a human-readable name for a light's current color, such as
+// "warm white" or "teal". A light that is off is reported as "off".
+func (l *Light) ColorName() string {
+	if !l.State.IsOn() {
+		return "off"
+	}
+
+	if l.State.ColorMode == ColorModeCT {
+		if l.State.Ct >= 300 {
+			return "warm white"
+		}
+		return "white"
+	}
+
+	h, s, lum := l.GetColorHSL()
+
+	// colors with very little saturation are white, and dim whites look warm
+	if s < 0.15 {
+		if lum < 0.5 {
+			return "warm white"
+		}
+		return "white"
+	}
+
+	for _, n := range hueNames {
+		if h < n.max {
+			return n.name
+		}
+	}
+	return "red"
+}
+
 // ByID is a Light array used for sorting
 type ByID []Light
 
@@ -119,22 +618,43 @@ func (b ByID) Less(i, j int) bool { return b[i].ID < b[j].ID }
 // Scene describes the states of a group of lights.
 type Scene struct {
 	hueScene
-	ID        string
-	ShortName string
+	ID        string `json:"id"`
+	ShortName string `json:"shortname"`
 }
 
 type hueScene struct {
 	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Group       string   `json:"group"`
 	Owner       string   `json:"owner"`
-	LastUpdated string   `json:"lastupdated"`
+	LastUpdated HueTime  `json:"lastupdated"`
 	Lights      []string `json:"lights"`
 	Version     int      `json:"version"`
 }
 
+// Scene versions. Version 1 scenes store their light states in the lights
+// themselves, so the hub can't report them, and they can't be edited.
+// Version 2 scenes store their light states in the hub.
+const (
+	SceneVersion1 = 1
+	SceneVersion2 = 2
+)
+
+// IsLegacy returns true if a scene is a version 1 scene.
+func (s Scene) IsLegacy() bool {
+	return s.Version < SceneVersion2
+}
+
 func (s Scene) String() string {
 	return fmt.Sprintf("%s [%s]", s.Name, strings.Join(s.Lights, ", "))
 }
 
+// SceneDetail is a Scene along with the light states stored in it.
+type SceneDetail struct {
+	Scene
+	LightStates map[string]LightState `json:"lightstates"`
+}
+
 // ByName is a Scene array used for sorting
 type ByName []Scene
 
@@ -145,75 +665,349 @@ func (b ByName) Less(i, j int) bool { return b[i].Name < b[j].Name }
 // Group represents a group of lights.
 type Group struct {
 	hueGroup
-	ID string
+	ID string `json:"id"`
 }
 
 type hueGroup struct {
-	Name   string     `json:"name"`
-	Lights []string   `json:"lights"`
-	Type   string     `json:"type"`
-	State  LightState `json:"action"`
+	Name        string     `json:"name"`
+	Lights      []string   `json:"lights"`
+	Type        string     `json:"type"`
+	State       LightState `json:"action"`
+	GroupStatus struct {
+		AnyOn bool `json:"any_on"`
+		AllOn bool `json:"all_on"`
+	} `json:"state"`
+
+	// Stream is only set for entertainment groups.
+	Stream *GroupStream `json:"stream,omitempty"`
+}
+
+// AnyOn returns true if any light in a group is on.
+func (g *Group) AnyOn() bool {
+	return g.GroupStatus.AnyOn
+}
+
+// AllOn returns true if all the lights in a group are on.
+func (g *Group) AllOn() bool {
+	return g.GroupStatus.AllOn
+}
+
+// GetColorRGB returns a group's color as an RGB value. The color is converted
+// using the gamut of the group's lights, which are looked up in lights. If the
+// lights aren't all the same model, or none are found, the default gamut is
+// used.
+func (g *Group) GetColorRGB(lights map[string]Light) (uint8, uint8, uint8) {
+	model := ""
+	for i, id := range g.Lights {
+		light, ok := lights[id]
+		if !ok || (i > 0 && light.Model != model) {
+			model = ""
+			break
+		}
+		model = light.Model
+	}
+	return stateToRGB(GetGamut(model), g.State)
+}
+
+// APIError is an error reported by a hub.
+type APIError struct {
+	Type        int    `json:"type"`
+	Address     string `json:"address"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	return e.Description
+}
+
+// ErrorCategory is a broad kind of APIError, which is stable across hub
+// firmware versions even when error descriptions aren't.
+type ErrorCategory string
+
+// Error categories. Errors with types that don't have a category are
+// CategoryOther. Requests rejected because the hub is receiving too many
+// return ErrBridgeBusy rather than an APIError.
+const (
+	CategoryUnauthorized          ErrorCategory = "unauthorized"
+	CategoryNotFound              ErrorCategory = "not found"
+	CategoryInvalidParameter      ErrorCategory = "invalid parameter"
+	CategoryInvalidValue          ErrorCategory = "invalid value"
+	CategoryParameterUnmodifiable ErrorCategory = "parameter unmodifiable"
+	CategoryLinkButton            ErrorCategory = "link button not pressed"
+	CategoryDeviceOff             ErrorCategory = "device off"
+	CategoryBusy                  ErrorCategory = "busy"
+	CategoryOther                 ErrorCategory = "other"
+)
+
+// errorCategories maps hub error types to categories.
+var errorCategories = map[int]ErrorCategory{
+	1:   CategoryUnauthorized,
+	3:   CategoryNotFound,
+	6:   CategoryInvalidParameter,
+	7:   CategoryInvalidValue,
+	8:   CategoryParameterUnmodifiable,
+	101: CategoryLinkButton,
+	201: CategoryDeviceOff,
+	901: CategoryBusy,
 }
 
-// Session is a handle used to interact with a specific hub.
+// Category returns the category of an error based on its type.
+func (e *APIError) Category() ErrorCategory {
+	if category, ok := errorCategories[e.Type]; ok {
+		return category
+	}
+	return CategoryOther
+}
+
+// Session is a handle used to interact with a specific hub. A Session is safe
+// for concurrent use by multiple goroutines, and copies of a Session share the
+// same settings. A zero Session gets default settings when it's first used;
+// copies made before then don't share them.
 type Session struct {
 	ipAddress string
 	username  string
+	clientKey string
+
+	// shared holds the mutable settings; use settings to access it
+	shared *sessionSettings
 }
 
+// sessionSettings holds the mutable settings of a session.
+type sessionSettings struct {
+	// mu guards the settings below
+	mu             sync.RWMutex
+	retries        int
+	clampStates    bool
+	dryRun         bool
+	recorded       []RecordedCommand
+	traceFunc      func(RequestTrace)
+	cache          *lightsCache
+	bridgeModel    string
+	checkReachable bool
+	timezones      []string
+
+	// ctRanges holds the color temperature ranges of lights that have been
+	// read, keyed by light ID
+	ctRanges map[string][2]int
+}
+
+// settingsMu guards the creation of settings for zero Sessions.
+var settingsMu sync.Mutex
+
+// RecordedCommand is a write request that a session in dry-run mode recorded
+// instead of sending.
+type RecordedCommand struct {
+	Method string
+	URL    string
+	Body   string
+}
+
+// DefaultRetries is the number of times a session will retry a request that
+// failed because the hub was busy.
+const DefaultRetries = 2
+
 // GetHubs returns a list of hubs.
-// This function uses the meethue.com service for locating hubs.
-func GetHubs() ([]Hub, error) {
+// This function uses the meethue.com service for locating hubs. The IP
+// addresses of known hubs may also be given; those that respond are included
+// in the list, so hubs can still be found if meethue.com can't be reached.
+// Hubs are deduplicated by ID. An error is only returned if no hubs were
+// found.
+func GetHubs(knownIPAddresses ...string) ([]Hub, error) {
 	var hubs []Hub
-	err := restGet("https://www.meethue.com/api/nupnp", &hubs)
+	seen := map[string]bool{}
+
+	for _, ipAddress := range knownIPAddresses {
+		hub, err := getHub(ipAddress)
+		if err != nil {
+			log.Printf("Known hub %s is unreachable: %v", ipAddress, err)
+			continue
+		}
+		if !seen[hub.ID] {
+			seen[hub.ID] = true
+			hubs = append(hubs, hub)
+		}
+	}
+
+	var discovered []Hub
+	err := restGet(context.Background(), "https://www.meethue.com/api/nupnp", &discovered)
+	for _, hub := range discovered {
+		hub.ID = strings.ToLower(hub.ID)
+		if !seen[hub.ID] {
+			seen[hub.ID] = true
+			hubs = append(hubs, hub)
+		}
+	}
+
+	if err != nil && len(hubs) > 0 {
+		log.Printf("Unable to discover hubs: %v", err)
+		err = nil
+	}
 	return hubs, err
 }
 
+// getHub returns a Hub describing the hub at an IP address, using the
+// unauthenticated subset of the hub's configuration.
+func getHub(ipAddress string) (hub Hub, err error) {
+	var config PublicConfig
+	if config, err = GetBridgeConfig(ipAddress); err != nil {
+		return
+	}
+
+	return Hub{
+		ID:         strings.ToLower(config.BridgeID),
+		IPAddress:  ipAddress,
+		MacAddress: config.MacAddress,
+		Name:       config.Name,
+	}, nil
+}
+
+// DefaultDeviceType is the device type used to identify sessions created by
+// NewSession.
+const DefaultDeviceType = "go-hue#application"
+
 // NewSession creates a new session for a hub. This involves creating a new
 // user on the hub. The username will be randomly generated by the hub.
 func NewSession(ipAddress string) (session Session, err error) {
-	postData := map[string]string{"devicetype": "go-hue#application"}
+	return createUser(ipAddress, DefaultDeviceType)
+}
+
+// NewSessionWithDeviceType creates a new session for a hub like NewSession,
+// but identifies the application to the hub with deviceType, which should have
+// the form "<application>#<device>".
+func NewSessionWithDeviceType(ipAddress string, deviceType string) (session Session, err error) {
+	return createUser(ipAddress, deviceType)
+}
+
+// NewSessionWait creates a new session for a hub like NewSession, but if the
+// hub's link button hasn't been pressed, it keeps trying until the button is
+// pressed or ctx is cancelled. The deviceType identifies the application to the
+// hub, and should have the form "<application>#<device>".
+func NewSessionWait(ctx context.Context, ipAddress string, deviceType string) (Session, error) {
+	ticker := time.NewTicker(linkButtonInterval)
+	defer ticker.Stop()
+
+	for {
+		session, err := createUser(ipAddress, deviceType)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Type != errLinkButtonNotPressed {
+			return session, err
+		}
+
+		log.Printf("Waiting for link button to be pressed...")
+		select {
+		case <-ctx.Done():
+			return session, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Connect creates a new session for a hub, waiting until the hub's link button
+// is pressed or ctx is cancelled. The appName identifies the application to
+// the hub.
+func (h Hub) Connect(ctx context.Context, appName string) (Session, error) {
+	return NewSessionWait(ctx, h.IPAddress, appName)
+}
+
+// errUnauthorizedUser is the API error type returned when a request uses a
+// username that isn't on the hub's whitelist.
+const errUnauthorizedUser = 1
+
+// IsAuthorized returns true if the session's username is still authorized to
+// use the hub. It returns false and no error if the hub reports that the user
+// is unauthorized, as happens after a factory reset or whitelist cleanup, and
+// an error if the hub couldn't be checked.
+func (s *Session) IsAuthorized() (bool, error) {
+	var data json.RawMessage
+	err := s.get(s.URL()+"/groups/0", &data)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Type == errUnauthorizedUser {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// errLinkButtonNotPressed is the API error type returned when creating a user
+// before the hub's link button has been pressed.
+const errLinkButtonNotPressed = 101
+
+// linkButtonInterval is how often NewSessionWait tries to create a user.
+const linkButtonInterval = time.Second
+
+// createUser creates a new user on a hub and returns a session for it.
+func createUser(ipAddress string, deviceType string) (session Session, err error) {
+	postData := map[string]interface{}{
+		"devicetype":        deviceType,
+		"generateclientkey": true,
+	}
 
 	var data []byte
-	if data, err = restPost("http://"+ipAddress+"/api/", postData); err != nil {
+	if data, err = restPost(context.Background(), "http://"+ipAddress+"/api/", postData); err != nil {
 		return
 	}
 
 	log.Printf("Got from hub: %s", string(data))
 
-	var responses []struct {
-		Success struct {
-			Username string `json:"username"`
-		} `json:"success"`
-		Error struct {
-			Description string `json:"description"`
-		} `json:"error"`
-	}
-
-	dec := json.NewDecoder(bytes.NewReader(data))
-	if err = dec.Decode(&responses); err != nil {
+	var responses []restResponse
+	if responses, err = parseResponses(data); err != nil {
 		return
 	}
 
-	response := responses[0]
-	if response.Success.Username != "" {
-		session = Session{
-			ipAddress: ipAddress,
-			username:  response.Success.Username,
-		}
-	} else {
-		err = errors.New(response.Error.Description)
+	username, _ := responses[0].Success["username"].(string)
+	if username == "" {
+		err = errors.New("No username in hub response")
+		return
 	}
 
+	session = newSession(ipAddress, username)
+	session.clientKey, _ = responses[0].Success["clientkey"].(string)
 	return
 }
 
 // OpenSession opens an existing session on a hub.
 func OpenSession(ipAddress string, username string) Session {
+	return newSession(ipAddress, username)
+}
+
+func newSession(ipAddress string, username string) Session {
 	return Session{
 		ipAddress: ipAddress,
 		username:  username,
+		shared:    newSessionSettings(),
+	}
+}
+
+func newSessionSettings() *sessionSettings {
+	return &sessionSettings{retries: DefaultRetries}
+}
+
+// settings returns a session's settings, creating them if the session is a
+// zero Session.
+func (s *Session) settings() *sessionSettings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	if s.shared == nil {
+		s.shared = newSessionSettings()
 	}
+	return s.shared
+}
+
+// SetRetries sets the number of times a session will retry a request that
+// failed because the hub was busy. A value of 0 disables retries.
+func (s *Session) SetRetries(retries int) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.retries = retries
+}
+
+// Retries returns the number of times a session will retry a request that
+// failed because the hub was busy.
+func (s *Session) Retries() int {
+	st := s.settings()
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.retries
 }
 
 // IPAddress returns the IP address of a session.
@@ -226,6 +1020,13 @@ func (s *Session) Username() string {
 	return s.username
 }
 
+// ClientKey returns the client key generated for a session by NewSession,
+// which is used for entertainment streaming. It is empty for sessions opened
+// with OpenSession.
+func (s *Session) ClientKey() string {
+	return s.clientKey
+}
+
 // URL returns the URL a session uses to control a hub.
 func (s *Session) URL() string {
 	return "http://" + s.ipAddress + "/api/" + s.username
@@ -233,99 +1034,875 @@ func (s *Session) URL() string {
 
 // Lights returns a map of the Lights available from session's hub.
 func (s *Session) Lights() (lights map[string]Light, err error) {
-	if err = restGet(s.URL()+"/lights", &lights); err != nil {
+	return s.lights(context.Background())
+}
+
+func (s *Session) lights(ctx context.Context) (lights map[string]Light, err error) {
+	if err = s.getContext(ctx, s.URL()+"/lights", &lights); err != nil {
 		return
 	}
 	for id, light := range lights {
 		light.ID = id
 		lights[id] = light
 	}
+	s.rememberLights(lights)
 	return
 }
 
-// Scenes returns a map of the Scenes available from the session's hub.
-func (s *Session) Scenes() (scenes map[string]Scene, err error) {
-	if err = restGet(s.URL()+"/scenes", &scenes); err != nil {
+// LightsByUniqueID returns the lights on the hub keyed by their unique IDs.
+// Unlike the IDs assigned by the hub, unique IDs don't change when a light is
+// re-paired.
+func (s *Session) LightsByUniqueID() (lights map[string]Light, err error) {
+	var all map[string]Light
+	if all, err = s.Lights(); err != nil {
 		return
 	}
-	re, _ := regexp.Compile("\\son\\s\\d+$")
-	for id, scene := range scenes {
-		scene.ShortName = re.ReplaceAllString(scene.Name, "")
-		scene.ID = id
-		scenes[id] = scene
+	lights = make(map[string]Light, len(all))
+	for _, light := range all {
+		lights[light.UniqueID] = light
 	}
 	return
 }
 
-// Groups returns a map of the Groups available from the session's hub.
-func (s *Session) Groups() (groups map[string]Group, err error) {
-	if err = restGet(s.URL()+"/groups", &groups); err != nil {
+// LightByUniqueID returns the light with a given unique ID.
+func (s *Session) LightByUniqueID(uid string) (light Light, err error) {
+	var lights map[string]Light
+	if lights, err = s.Lights(); err != nil {
 		return
 	}
-	for id, group := range groups {
-		group.ID = id
-		groups[id] = group
+	for _, l := range lights {
+		if strings.EqualFold(l.UniqueID, uid) {
+			return l, nil
+		}
 	}
+	err = fmt.Errorf("No light with unique ID %s", uid)
 	return
 }
 
-// SetScene sets the scene for group 0.
-func (s *Session) SetScene(id string) error {
-	data := map[string]string{"scene": id}
-	resp, err := restPut(s.URL()+"/groups/0/action", &data)
-	log.Printf("Response: %#v", resp)
-	return err
+// GetLight returns a specific light.
+func (s *Session) GetLight(id string) (light Light, err error) {
+	if err = s.get(s.URL()+"/lights/"+id, &light); err != nil {
+		return
+	}
+	light.ID = id
+	s.rememberLights(map[string]Light{id: light})
+	return
 }
 
-// SetLightState sets the state of a specific light.
+// Values of the lastscan field returned by NewLights, other than a timestamp.
+const (
+	ScanActive = "active"
+	ScanNone   = "none"
+)
+
+// SearchForNewLights tells the hub to start searching for new lights. The
+// search runs for about 40 seconds; use NewLights to get the results. If
+// serials are given, the hub will search for lights with those serial
+// numbers, which are needed for lights that were paired with another hub.
+func (s *Session) SearchForNewLights(serials ...string) error {
+	var data interface{}
+	if len(serials) > 0 {
+		data = map[string][]string{"deviceid": serials}
+	}
+	resp, err := s.post(s.URL()+"/lights", data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// NewLights returns the lights found by the most recent search for new lights,
+// along with the status of the search, which is ScanActive while a search is
+// running, ScanNone if no search has been run, or otherwise the time the last
+// search finished.
+func (s *Session) NewLights() (lights map[string]Light, lastScan string, err error) {
+	var data map[string]json.RawMessage
+	if err = s.get(s.URL()+"/lights/new", &data); err != nil {
+		return
+	}
+
+	lights = map[string]Light{}
+	for id, value := range data {
+		if id == "lastscan" {
+			if err = json.Unmarshal(value, &lastScan); err != nil {
+				return
+			}
+			continue
+		}
+
+		var light Light
+		if err = json.Unmarshal(value, &light); err != nil {
+			return
+		}
+		light.ID = id
+		lights[id] = light
+	}
+
+	return
+}
+
+// ScanState describes a hub's search for new devices of one kind.
+type ScanState struct {
+	// Scanning is true while a search is running.
+	Scanning bool
+
+	// LastScan is the time the last search finished. It is zero if a search
+	// is running or no search has been run.
+	LastScan time.Time
+}
+
+// Idle returns true if no search is running.
+func (s ScanState) Idle() bool {
+	return !s.Scanning
+}
+
+// ScanStatus describes a hub's searches for new lights and sensors.
+type ScanStatus struct {
+	Lights  ScanState
+	Sensors ScanState
+}
+
+// ScanStatus returns the status of the hub's searches for new lights and
+// sensors.
+func (s *Session) ScanStatus() (status ScanStatus, err error) {
+	if status.Lights, err = s.scanState("/lights/new"); err != nil {
+		return
+	}
+	status.Sensors, err = s.scanState("/sensors/new")
+	return
+}
+
+// Scenes returns a map of the Scenes available from the session's hub.
+func (s *Session) Scenes() (scenes map[string]Scene, err error) {
+	return s.scenes(context.Background())
+}
+
+func (s *Session) scenes(ctx context.Context) (scenes map[string]Scene, err error) {
+	if err = s.getContext(ctx, s.URL()+"/scenes", &scenes); err != nil {
+		return
+	}
+	for id, scene := range scenes {
+		scene.ShortName = sceneShortName(scene.Name)
+		scene.ID = id
+		scenes[id] = scene
+	}
+	return
+}
+
+// GetScene returns a specific scene, including the light states stored in it.
+func (s *Session) GetScene(id string) (scene SceneDetail, err error) {
+	if err = s.get(s.URL()+"/scenes/"+id, &scene); err != nil {
+		return
+	}
+	scene.ShortName = sceneShortName(scene.Name)
+	scene.ID = id
+	return
+}
+
+// Groups returns a map of the Groups available from the session's hub.
+func (s *Session) Groups() (groups map[string]Group, err error) {
+	return s.groups(context.Background())
+}
+
+func (s *Session) groups(ctx context.Context) (groups map[string]Group, err error) {
+	if err = s.getContext(ctx, s.URL()+"/groups", &groups); err != nil {
+		return
+	}
+	for id, group := range groups {
+		group.ID = id
+		groups[id] = group
+	}
+	return
+}
+
+// GetGroup returns a specific group.
+func (s *Session) GetGroup(id string) (group Group, err error) {
+	if err = s.get(s.URL()+"/groups/"+id, &group); err != nil {
+		return
+	}
+	group.ID = id
+	return
+}
+
+// SetScene recalls a scene. Group scenes are recalled in their own group, and
+// other scenes are recalled in group 0.
+func (s *Session) SetScene(id string) error {
+	scene, err := s.GetScene(id)
+	if err != nil {
+		return err
+	}
+
+	if scene.IsLegacy() {
+		log.Printf("Warning: scene %s is a version %d scene; use UpgradeScene to convert it", id, scene.Version)
+	}
+
+	group := "0"
+	if scene.Group != "" {
+		group = scene.Group
+	}
+
+	data := map[string]string{"scene": id}
+	resp, err := s.put(s.URL()+"/groups/"+group+"/action", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// SetSceneThen recalls a scene and adjusts it with overrides. The hub accepts
+// On, Brightness, and TransitionTime alongside a scene recall, so those are
+// sent in the same request and the lights change in one step. Any other
+// override fields (colors or effects) can't accompany a recall and are applied
+// to the scene's group in a second request.
+func (s *Session) SetSceneThen(id string, overrides LightState) error {
+	scene, err := s.GetScene(id)
+	if err != nil {
+		return err
+	}
+
+	group := "0"
+	if scene.Group != "" {
+		group = scene.Group
+	}
+
+	overrides, err = s.prepareState(overrides, "")
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"scene": id}
+	if overrides.On != nil {
+		data["on"] = *overrides.On
+	}
+	if overrides.Brightness != 0 {
+		data["bri"] = overrides.Brightness
+	}
+	if overrides.TransitionTime != nil {
+		data["transitiontime"] = *overrides.TransitionTime
+	}
+
+	log.Printf("Setting scene with overrides: %#v", data)
+	resp, err := s.put(s.URL()+"/groups/"+group+"/action", &data)
+	log.Printf("Response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	rest := overrides
+	rest.On = nil
+	rest.Brightness = 0
+	if rest.Equal(LightState{TransitionTime: rest.TransitionTime}, 0) {
+		return nil
+	}
+	return s.SetGroupState(group, rest)
+}
+
+// ApplySceneToLights applies the states stored in a scene to some of the
+// scene's lights, leaving its other lights unchanged. Requested lights that
+// aren't in the scene are ignored.
+func (s *Session) ApplySceneToLights(sceneID string, lightIDs []string) error {
+	scene, err := s.GetScene(sceneID)
+	if err != nil {
+		return err
+	}
+
+	states := map[string]LightState{}
+	for _, id := range lightIDs {
+		if state, ok := scene.LightStates[id]; ok {
+			states[id] = state
+		}
+	}
+	return s.SetLightStates(states)
+}
+
+// SetSceneByName recalls the scene with the given name. Names are compared
+// case-insensitively against both the full and short scene names. If several
+// scenes match, the most recently updated one is used.
+func (s *Session) SetSceneByName(name string) error {
+	scenes, err := s.Scenes()
+	if err != nil {
+		return err
+	}
+
+	var match *Scene
+	for _, scene := range scenes {
+		if !strings.EqualFold(scene.Name, name) && !strings.EqualFold(scene.ShortName, name) {
+			continue
+		}
+		if match == nil || scene.LastUpdated.After(match.LastUpdated.Time) {
+			scene := scene
+			match = &scene
+		}
+	}
+
+	if match == nil {
+		return fmt.Errorf("No scene named '%s'", name)
+	}
+
+	return s.SetScene(match.ID)
+}
+
+// SetLightState sets the state of a specific light. If the session checks
+// reachability (see SetCheckReachable) and the light is unreachable,
+// ErrLightUnreachable is returned.
 func (s *Session) SetLightState(id string, state LightState) error {
-	// clear the colormode before posting
-	state.ColorMode = ""
+	_, err := s.setLightState(id, state, true)
+	return err
+}
+
+// setLightState validates and sends a light's state, first checking that the
+// light is reachable if check is true and the session checks reachability.
+// Callers that already have the light's state, or that send many updates in
+// a row, check it themselves instead.
+func (s *Session) setLightState(id string, state LightState, check bool) ([]restResponse, error) {
+	state, err := s.prepareState(state, id)
+	if err != nil {
+		return nil, err
+	}
+	if check {
+		if err = s.checkReachable(id); err != nil {
+			return nil, err
+		}
+	}
 	log.Printf("Setting light state to: %#v", state)
-	resp, err := restPut(s.URL()+"/lights/"+id+"/state", state)
+	resp, err := s.put(s.URL()+"/lights/"+id+"/state", state)
+	log.Printf("Response: %#v", resp)
+	return resp, err
+}
+
+// SetLightStateRaw sets the state of a specific light using a pre-encoded
+// JSON body, such as a cached encoding of a LightState. The body is sent as-is,
+// without being validated or clamped.
+func (s *Session) SetLightStateRaw(id string, body json.RawMessage) error {
+	resp, err := s.put(s.URL()+"/lights/"+id+"/state", body)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// ToggleLight turns a light off if it's on, or on if it's off, and returns
+// whether the light is now on. Only the on state is sent, so the light's color
+// and brightness are unchanged. If the light is unreachable,
+// ErrLightUnreachable is returned.
+func (s *Session) ToggleLight(id string) (on bool, err error) {
+	light, err := s.GetLight(id)
+	if err != nil {
+		return
+	}
+	if !light.State.Reachable {
+		return light.State.IsOn(), ErrLightUnreachable
+	}
+
+	on = !light.State.IsOn()
+	data := map[string]bool{"on": on}
+	resp, err := s.put(s.URL()+"/lights/"+id+"/state", &data)
+	log.Printf("Response: %#v", resp)
+	if err != nil {
+		return !on, err
+	}
+	return
+}
+
+// SetLightStates sets the states of several lights, keyed by light ID. Every
+// light is updated even if some updates fail; the first error is returned. If
+// the session checks reachability, the lights are read once before any are
+// updated.
+func (s *Session) SetLightStates(states map[string]LightState) error {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var lights map[string]Light
+	if s.checksReachable() {
+		var err error
+		if lights, err = s.Lights(); err != nil {
+			return err
+		}
+	}
+
+	var firstErr error
+	for _, id := range ids {
+		err := s.reachableError(lights, id)
+		if err == nil {
+			_, err = s.setLightState(id, states[id], false)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SetGroupState sets the state of all the lights in a specific group.
+func (s *Session) SetGroupState(id string, state LightState) error {
+	state, err := s.prepareState(state, "")
+	if err != nil {
+		return err
+	}
+	log.Printf("Setting group state to: %#v", state)
+	resp, err := s.put(s.URL()+"/groups/"+id+"/action", state)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// SetGroupBrightness sets the brightness of all the lights in a group without
+// changing anything else, including whether they're on. The brightness is
+// clamped to [MinBrightness, MaxBrightness].
+func (s *Session) SetGroupBrightness(id string, bri int) error {
+	data := map[string]int{"bri": int(clamp(float64(bri), MinBrightness, MaxBrightness))}
+	log.Printf("Setting group brightness to: %#v", data)
+	resp, err := s.put(s.URL()+"/groups/"+id+"/action", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// SetGroupTemperature sets the color temperature, in mireds, of all the
+// lights in a group without changing anything else. The temperature is
+// clamped to [MinCt, MaxCt].
+func (s *Session) SetGroupTemperature(id string, mired int) error {
+	data := map[string]int{"ct": int(clamp(float64(mired), MinCt, MaxCt))}
+	log.Printf("Setting group color temperature to: %#v", data)
+	resp, err := s.put(s.URL()+"/groups/"+id+"/action", &data)
 	log.Printf("Response: %#v", resp)
 	return err
 }
 
+// SetLightStateReturning sets the state of a specific light and returns the
+// state values the hub reported as changed, keyed by field name (e.g., "on" or
+// "bri").
+func (s *Session) SetLightStateReturning(id string, state LightState) (map[string]interface{}, error) {
+	resp, err := s.setLightState(id, state, true)
+	if err != nil {
+		return nil, err
+	}
+	return successValues(resp), nil
+}
+
+// xyEpsilon is the tolerance used when comparing xy values, which the hub
+// rounds when storing them.
+const xyEpsilon = 0.001
+
+// EnsureLightState sets the state of a specific light only if its current
+// state differs from the desired one, and returns true if the state was
+// changed. Only the on, brightness, color temperature, and xy fields set in
+// desired are compared.
+func (s *Session) EnsureLightState(id string, desired LightState) (bool, error) {
+	light, err := s.GetLight(id)
+	if err != nil {
+		return false, err
+	}
+
+	current := light.State
+	differs := (desired.On != nil && *desired.On != current.IsOn()) ||
+		(desired.Brightness != 0 && desired.Brightness != current.Brightness) ||
+		(desired.Ct != 0 && desired.Ct != current.Ct) ||
+		(desired.Xy != [2]float64{} &&
+			(math.Abs(desired.Xy[0]-current.Xy[0]) > xyEpsilon ||
+				math.Abs(desired.Xy[1]-current.Xy[1]) > xyEpsilon))
+
+	if !differs {
+		log.Printf("Light %s already in desired state", id)
+		return false, nil
+	}
+	if s.checksReachable() && !current.Reachable {
+		return false, ErrLightUnreachable
+	}
+
+	_, err = s.setLightState(id, desired, false)
+	return true, err
+}
+
 // SetLightName sets the name of a specific light.
 func (s *Session) SetLightName(id string, name string) error {
 	log.Printf("Setting light name to: %#v", name)
 	data := map[string]string{"name": name}
-	resp, err := restPut(s.URL()+"/lights/"+id, &data)
+	resp, err := s.put(s.URL()+"/lights/"+id, &data)
 	log.Printf("Response: %#v", resp)
 	return err
 }
 
-// support functions ///////////////////////////////////////////////////
+// maxNameLength is the maximum length of a scene or group name.
+const maxNameLength = 32
 
-type restResponse struct {
-	Success map[string]interface{} `json:"success"`
-	Error   map[string]interface{} `json:"error"`
+// SetSceneName sets the name of a specific scene. Names must be 1 to 32
+// characters long.
+func (s *Session) SetSceneName(id string, name string) error {
+	return s.setName("/scenes/"+id, name)
 }
 
-func restGet(url string, item interface{}) error {
-	resp, err := http.Get(url)
+// UpdateSceneLightState changes the state stored in a scene for a single
+// light. If the scene or light doesn't exist, an *APIError is returned.
+func (s *Session) UpdateSceneLightState(sceneID string, lightID string, state LightState) error {
+	state, err := s.prepareState(state, lightID)
 	if err != nil {
 		return err
 	}
+	log.Printf("Setting scene %s state for light %s to: %#v", sceneID, lightID, state)
+	resp, err := s.put(s.URL()+"/scenes/"+sceneID+"/lightstates/"+lightID, state)
+	log.Printf("Response: %#v", resp)
+	return err
+}
 
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
+// StoreCurrentInScene replaces the states stored in a scene with the current
+// states of its lights. If the scene doesn't exist, an *APIError is returned.
+func (s *Session) StoreCurrentInScene(sceneID string) error {
+	data := map[string]bool{"storelightstate": true}
+	resp, err := s.put(s.URL()+"/scenes/"+sceneID, &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// SetGroupName sets the name of a specific group. Names must be 1 to 32
+// characters long.
+func (s *Session) SetGroupName(id string, name string) error {
+	return s.setName("/groups/"+id, name)
+}
+
+// setName sets the name of the resource at path, returning an APIError if
+// the name is an invalid length.
+func (s *Session) setName(path string, name string) error {
+	if len(name) == 0 || len(name) > maxNameLength {
+		return &APIError{
+			Type:        7,
+			Address:     path + "/name",
+			Description: fmt.Sprintf("invalid value, %s, for parameter, name", name),
+		}
+	}
+
+	log.Printf("Setting name of %s to: %#v", path, name)
+	data := map[string]string{"name": name}
+	resp, err := s.put(s.URL()+path, &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// SetClampStates determines what a session does with light states that have
+// values outside the ranges accepted by the hub. By default, SetLightState and
+// SetGroupState return an error for such states; if clamp is true, the values
+// are clamped to the valid ranges instead. Once a light has been read, the
+// color temperatures sent to it are checked against the range it supports
+// rather than the hub's.
+func (s *Session) SetClampStates(clamp bool) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.clampStates = clamp
+}
+
+// SetCheckReachable determines whether light updates check that a light is
+// reachable. The hub accepts updates for unreachable lights, but they have no
+// effect; when checking is enabled, SetLightState and SetLightStateReturning
+// return ErrLightUnreachable for such lights instead. The check requires an
+// extra request for every update, so it's disabled by default. Methods that
+// already have a light's state, such as EnsureLightState, use that instead,
+// and methods that send a series of updates, such as FadeWith, only check
+// before the first one.
+func (s *Session) SetCheckReachable(check bool) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.checkReachable = check
+}
+
+// SetDryRun turns dry-run mode on or off. In dry-run mode, requests that would
+// change the hub's state are logged and recorded instead of being sent, and
+// succeed without returning any data. Requests that only read from the hub are
+// sent normally.
+func (s *Session) SetDryRun(dryRun bool) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.dryRun = dryRun
+}
+
+// RecordedCommands returns the write requests recorded in dry-run mode.
+func (s *Session) RecordedCommands() []RecordedCommand {
+	st := s.settings()
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]RecordedCommand(nil), st.recorded...)
+}
+
+// ClearRecordedCommands discards the write requests recorded in dry-run mode.
+func (s *Session) ClearRecordedCommands() {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.recorded = nil
+}
+
+// recordDryRun records a write request if the session is in dry-run mode, and
+// returns true if it was recorded.
+func (s *Session) recordDryRun(method string, url string, data interface{}) bool {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.dryRun {
+		return false
+	}
+
+	var body []byte
+	if data != nil {
+		body, _ = json.Marshal(data)
+	}
+	log.Printf("Dry run: %s %s: %s", method, url, body)
+	st.recorded = append(st.recorded, RecordedCommand{method, url, string(body)})
+	return true
+}
+
+// ErrLightUnreachable is returned when updating a light that the hub can't
+// communicate with, since the update would have no effect.
+var ErrLightUnreachable = errors.New("Light is unreachable")
+
+// checksReachable returns true if the session checks that lights are
+// reachable before updating them. Sessions in dry-run mode don't.
+func (s *Session) checksReachable() bool {
+	st := s.settings()
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.checkReachable && !st.dryRun
+}
 
-	dec := json.NewDecoder(bytes.NewReader(body))
-	err = dec.Decode(item)
+// checkReachable reads a light and returns ErrLightUnreachable if it's
+// unreachable, if the session checks reachability.
+func (s *Session) checkReachable(id string) error {
+	if !s.checksReachable() {
+		return nil
+	}
+
+	light, err := s.GetLight(id)
 	if err != nil {
 		return err
 	}
+	return s.reachableError(map[string]Light{id: light}, id)
+}
 
+// reachableError returns ErrLightUnreachable if the session checks
+// reachability and a light in lights is unreachable. Lights that aren't in
+// lights are assumed to be reachable.
+func (s *Session) reachableError(lights map[string]Light, id string) error {
+	if light, ok := lights[id]; ok && !light.State.Reachable && s.checksReachable() {
+		return ErrLightUnreachable
+	}
 	return nil
 }
 
-func restSend(url string, data interface{}, method string) ([]byte, error) {
+// prepareState validates or clamps a state before it's sent to the hub. If
+// lightID isn't empty and the session has read that light, its color
+// temperature is checked against the range the light supports.
+func (s *Session) prepareState(state LightState, lightID string) (LightState, error) {
+	// The colormode is read-only, and the hub rejects updates that include
+	// it. A state read from a light has values for every color mode, though,
+	// and the hub would apply them in its own order of precedence (xy, then
+	// ct, then hue and saturation), which can change the light's mode. So when
+	// the mode is set, only the color fields for that mode are sent.
+	switch state.ColorMode {
+	case ColorModeXY:
+		state.Ct, state.Hue, state.Saturation = 0, 0, 0
+	case ColorModeCT:
+		state.Xy, state.Hue, state.Saturation = [2]float64{}, 0, 0
+	case ColorModeHS:
+		state.Xy, state.Ct = [2]float64{}, 0
+	}
+	state.ColorMode = ""
+	state.Reachable = false
+
+	minCt, maxCt := MinCt, MaxCt
+	st := s.settings()
+	st.mu.RLock()
+	clampStates := st.clampStates
+	if ct, ok := st.ctRanges[lightID]; ok {
+		minCt, maxCt = ct[0], ct[1]
+	}
+	st.mu.RUnlock()
+
+	if clampStates {
+		return state.clamped(minCt, maxCt), nil
+	}
+	return state, state.validate(minCt, maxCt)
+}
+
+// rememberLights records the color temperature ranges of lights read from the
+// hub, so states sent to them can be checked against those ranges.
+func (s *Session) rememberLights(lights map[string]Light) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.ctRanges == nil {
+		st.ctRanges = map[string][2]int{}
+	}
+	for id, light := range lights {
+		min, max := light.CtRange()
+		st.ctRanges[id] = [2]int{min, max}
+	}
+}
+
+// support functions ///////////////////////////////////////////////////
+
+// scanState returns the search status reported by a new devices resource.
+func (s *Session) scanState(path string) (state ScanState, err error) {
+	var data struct {
+		LastScan string `json:"lastscan"`
+	}
+	if err = s.get(s.URL()+path, &data); err != nil {
+		return
+	}
+
+	switch data.LastScan {
+	case ScanActive:
+		state.Scanning = true
+	case ScanNone, "":
+	default:
+		var t HueTime
+		if err = t.UnmarshalJSON([]byte(strconv.Quote(data.LastScan))); err != nil {
+			return
+		}
+		state.LastScan = t.Time
+	}
+	return
+}
+
+var sceneSuffix = regexp.MustCompile("\\son\\s\\d+$")
+
+// sceneShortName returns a scene name without the "on <timestamp>" suffix
+// added by some apps.
+func sceneShortName(name string) string {
+	return sceneSuffix.ReplaceAllString(name, "")
+}
+
+type restResponse struct {
+	Success map[string]interface{} `json:"success"`
+	Error   *APIError              `json:"error"`
+}
+
+// errHubUnavailable is returned when a hub responds with HTTP 503.
+var errHubUnavailable = errors.New("Hub unavailable")
+
+// ErrBridgeBusy is returned when a hub rejects a request because it's
+// receiving too many (HTTP 429), which means the request wasn't applied.
+// Sessions retry such requests according to their retry setting.
+var ErrBridgeBusy = errors.New("Hub is busy")
+
+// retryDelay is the base delay between retries of a failed request.
+const retryDelay = 50 * time.Millisecond
+
+// isTransient returns true if err indicates a temporary hub condition that
+// may succeed if retried.
+func isTransient(err error) bool {
+	if err == errHubUnavailable || err == ErrBridgeBusy {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Type == 901
+}
+
+// withRetries calls f, retrying it with a short, jittered backoff while it
+// fails with a transient error.
+func (s *Session) withRetries(f func() error) error {
+	retries := s.Retries()
+	err := f()
+	for attempt := 1; attempt <= retries && isTransient(err); attempt++ {
+		delay := time.Duration(attempt)*retryDelay + time.Duration(rand.Int63n(int64(retryDelay)))
+		log.Printf("Hub busy (%v), retrying in %v", err, delay)
+		time.Sleep(delay)
+		err = f()
+	}
+	return err
+}
+
+func (s *Session) put(url string, data interface{}) (resp []restResponse, err error) {
+	if s.recordDryRun("PUT", url, data) {
+		return
+	}
+	err = s.withRetries(func() (err error) {
+		ctx, done := s.traceContext(context.Background(), "PUT", url)
+		defer done()
+		resp, err = restPut(ctx, url, data)
+		return
+	})
+	return
+}
+
+func (s *Session) post(url string, data interface{}) (resp []restResponse, err error) {
+	if s.recordDryRun("POST", url, data) {
+		return
+	}
+	err = s.withRetries(func() error {
+		ctx, done := s.traceContext(context.Background(), "POST", url)
+		defer done()
+		body, err := restPost(ctx, url, data)
+		if err != nil {
+			return err
+		}
+		resp, err = parseResponses(body)
+		return err
+	})
+	return
+}
+
+func (s *Session) delete(url string) (resp []restResponse, err error) {
+	if s.recordDryRun("DELETE", url, nil) {
+		return
+	}
+	err = s.withRetries(func() (err error) {
+		ctx, done := s.traceContext(context.Background(), "DELETE", url)
+		defer done()
+		resp, err = restDelete(ctx, url)
+		return
+	})
+	return
+}
+
+func (s *Session) create(url string, data interface{}) (id string, err error) {
+	if s.recordDryRun("POST", url, data) {
+		return
+	}
+	err = s.withRetries(func() (err error) {
+		ctx, done := s.traceContext(context.Background(), "POST", url)
+		defer done()
+		id, err = restCreate(ctx, url, data)
+		return
+	})
+	return
+}
+
+func (s *Session) get(url string, item interface{}) error {
+	return s.getContext(context.Background(), url, item)
+}
+
+func (s *Session) getContext(ctx context.Context, url string, item interface{}) error {
+	return s.withRetries(func() error {
+		ctx, done := s.traceContext(ctx, "GET", url)
+		defer done()
+		return restGet(ctx, url, item)
+	})
+}
+
+// Version is the version of this package.
+const Version = "0.1.0"
+
+// UserAgent is the User-Agent header sent with every request, which
+// identifies this package to hubs.
+var UserAgent = "go-hue/" + Version
+
+// restGet decodes the body of a GET request into item. If the hub responds
+// with an error message instead, it's returned as an *APIError.
+func restGet(ctx context.Context, url string, item interface{}) error {
+	body, err := restGetRaw(ctx, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, item)
+}
+
+func restSend(ctx context.Context, url string, data interface{}, method string) ([]byte, error) {
 	var body []byte
 	var err error
 
-	if data != nil {
+	switch data := data.(type) {
+	case nil:
+	case json.RawMessage:
+		// pre-encoded bodies are sent as-is
+		body = data
+	default:
 		body, err = json.Marshal(data)
 		if err != nil {
 			return nil, err
@@ -333,10 +1910,12 @@ func restSend(url string, data interface{}, method string) ([]byte, error) {
 	}
 
 	log.Printf(method+"ing to URL %s: %s", url, body)
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -345,37 +1924,136 @@ func restSend(url string, data interface{}, method string) ([]byte, error) {
 	}
 
 	defer resp.Body.Close()
-	body, _ = ioutil.ReadAll(resp.Body)
+	if err := statusError(resp); err != nil {
+		return nil, err
+	}
+	body, _ = readBody(resp)
 	return body, nil
 }
 
-func restPost(url string, data interface{}) ([]byte, error) {
-	return restSend(url, data, "POST")
+// statusError returns the error for a response whose status shows the hub was
+// too busy to handle the request, or nil.
+func statusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return errHubUnavailable
+	case http.StatusTooManyRequests:
+		return ErrBridgeBusy
+	}
+	return nil
 }
 
-func restPut(url string, data interface{}) (restResponse, error) {
-	body, err := restSend(url, data, "PUT")
+// bodyReader returns a reader for a response body, decompressing it if
+// necessary. Requests set Accept-Encoding explicitly, so the HTTP client
+// doesn't decompress responses itself.
+func bodyReader(resp *http.Response) (io.Reader, error) {
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return gzip.NewReader(resp.Body)
+	}
+	return resp.Body, nil
+}
+
+// readBody reads a response body, decompressing it if necessary.
+func readBody(resp *http.Response) ([]byte, error) {
+	reader, err := bodyReader(resp)
 	if err != nil {
-		return restResponse{}, err
+		return nil, err
 	}
+	return ioutil.ReadAll(reader)
+}
 
-	var messages []restResponse
-	dec := json.NewDecoder(bytes.NewReader(body))
-	err = dec.Decode(&messages)
+func restPost(ctx context.Context, url string, data interface{}) ([]byte, error) {
+	return restSend(ctx, url, data, "POST")
+}
 
-	if len(messages) == 0 {
-		return restResponse{}, err
+func restPut(ctx context.Context, url string, data interface{}) ([]restResponse, error) {
+	body, err := restSend(ctx, url, data, "PUT")
+	if err != nil {
+		return nil, err
+	}
+	return parseResponses(body)
+}
+
+func restDelete(ctx context.Context, url string) ([]restResponse, error) {
+	body, err := restSend(ctx, url, nil, "DELETE")
+	if err != nil {
+		return nil, err
+	}
+	return parseResponses(body)
+}
+
+// restCreate posts a new resource and returns the ID assigned to it by the
+// hub.
+func restCreate(ctx context.Context, url string, data interface{}) (string, error) {
+	body, err := restPost(ctx, url, data)
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := parseResponses(body)
+	if err != nil {
+		return "", err
 	}
 
-	message := messages[0]
+	id, _ := messages[0].Success["id"].(string)
+	return id, nil
+}
+
+// parseResponses decodes the messages in a hub response body, returning an
+// error if the hub reported one. Hubs normally respond with an array of
+// messages, but a single message is also accepted.
+func parseResponses(body []byte) ([]restResponse, error) {
+	var messages []restResponse
+	var err error
+
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(trimmed, &messages)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var message restResponse
+		if err = json.Unmarshal(trimmed, &message); err == nil {
+			messages = []restResponse{message}
+		}
+	default:
+		return nil, fmt.Errorf("Unexpected response from hub: %s", abbreviate(trimmed))
+	}
 
 	if err != nil {
-		return message, err
+		return messages, fmt.Errorf("Invalid response from hub (%v): %s", err, abbreviate(trimmed))
 	}
 
-	if message.Error != nil {
-		return message, errors.New(message.Error["description"].(string))
+	if len(messages) == 0 {
+		return messages, errors.New("Empty response from hub")
+	}
+
+	for _, message := range messages {
+		if message.Error != nil {
+			return messages, message.Error
+		}
 	}
 
-	return message, nil
+	return messages, nil
+}
+
+// abbreviate returns body as a string, truncated if it's long enough to
+// overwhelm an error message.
+func abbreviate(body []byte) string {
+	const max = 200
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
+
+// successValues collects the values from a set of success messages, keyed by
+// the last component of each message's resource path.
+func successValues(messages []restResponse) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, message := range messages {
+		for path, value := range message.Success {
+			values[path[strings.LastIndex(path, "/")+1:]] = value
+		}
+	}
+	return values
 }
@@ -0,0 +1,295 @@
+package hue
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetRetriesWhenHubUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		failures int
+		retries  int
+		wantErr  error
+	}{
+		{"503 retried", http.StatusServiceUnavailable, 2, 2, nil},
+		{"429 retried", http.StatusTooManyRequests, 1, 2, nil},
+		{"retries exhausted", http.StatusServiceUnavailable, 3, 2, errHubUnavailable},
+		{"retries disabled", http.StatusServiceUnavailable, 1, 0, errHubUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= test.failures {
+					w.WriteHeader(test.status)
+					return
+				}
+				w.Write([]byte(`{"1":{"name":"Lamp"}}`))
+			}))
+			defer server.Close()
+
+			session := OpenSession(strings.TrimPrefix(server.URL, "http://"), "user")
+			session.SetRetries(test.retries)
+
+			lights, err := session.Lights()
+			if err != test.wantErr {
+				t.Fatalf("Lights() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && lights["1"].Name != "Lamp" {
+				t.Errorf("Lights() = %v, want light 1 named Lamp", lights)
+			}
+		})
+	}
+}
+
+func TestSetColorHSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		h, s, v float64
+		want    map[string]interface{}
+	}{
+		{"red", 0, 1, 1, map[string]interface{}{"hue": float64(MaxHue), "sat": float64(MaxSaturation), "bri": float64(MaxBrightness)}},
+		{"white", 120, 0, 1, map[string]interface{}{"hue": float64(21845), "sat": float64(1), "bri": float64(MaxBrightness)}},
+		{"dim white at hue 0", 0, 0, 0.5, map[string]interface{}{"hue": float64(MaxHue), "sat": float64(1), "bri": float64(127)}},
+		{"green", 120, 0.5, 1, map[string]interface{}{"hue": float64(21845), "sat": float64(127), "bri": float64(MaxBrightness)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var light Light
+			if err := light.SetColorHSV(test.h, test.s, test.v); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := json.Marshal(light.State)
+			var got map[string]interface{}
+			json.Unmarshal(data, &got)
+			for key, want := range test.want {
+				if got[key] != want {
+					t.Errorf("SetColorHSV(%v, %v, %v) sends %s = %v, want %v (%s)", test.h, test.s, test.v, key, got[key], want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestColorNameInHSMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		h, s, v float64
+		wantH   float64
+		want    string
+	}{
+		{"red", 0, 1, 1, 0, "red"},
+		{"green", 120, 1, 1, 120, "green"},
+		{"blue", 240, 1, 1, 240, "blue"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var light Light
+			light.State.On = Bool(true)
+			if err := light.SetColorHSV(test.h, test.s, test.v); err != nil {
+				t.Fatal(err)
+			}
+			h, s, l := light.GetColorHSL()
+			if math.Abs(h-test.wantH) > 1 || s < 0.99 || math.Abs(l-0.5) > 0.01 {
+				t.Errorf("GetColorHSL() = (%v, %v, %v), want (%v, 1, 0.5)", h, s, l, test.wantH)
+			}
+			if name := light.ColorName(); name != test.want {
+				t.Errorf("ColorName() = %q, want %q", name, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateCtRange(t *testing.T) {
+	var light Light
+	light.Caps.Control.Ct.Min = 153
+	light.Caps.Control.Ct.Max = 454
+
+	tests := []struct {
+		ct         int
+		wantHub    bool
+		wantLight  bool
+		clampedFor int
+	}{
+		{0, true, true, 0},
+		{153, true, true, 153},
+		{454, true, true, 454},
+		{455, true, false, 454},
+		{500, true, false, 454},
+		{501, false, false, 454},
+		{100, false, false, 153},
+	}
+
+	for _, test := range tests {
+		state := LightState{Ct: test.ct}
+		if err := state.Validate(); (err == nil) != test.wantHub {
+			t.Errorf("Validate() with ct %d = %v, want valid %v", test.ct, err, test.wantHub)
+		}
+		if err := state.ValidateFor(&light); (err == nil) != test.wantLight {
+			t.Errorf("ValidateFor() with ct %d = %v, want valid %v", test.ct, err, test.wantLight)
+		}
+		if ct := state.ClampedFor(&light).Ct; ct != test.clampedFor {
+			t.Errorf("ClampedFor() with ct %d = %d, want %d", test.ct, ct, test.clampedFor)
+		}
+	}
+}
+
+func TestPartialStateEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		state LightState
+		want  string
+	}{
+		{"brightness only", LightState{Brightness: 200}, `{"bri":200}`},
+		{"on", LightState{On: Bool(true)}, `{"on":true}`},
+		{"off", LightState{On: Bool(false)}, `{"on":false}`},
+		{"off with brightness", LightState{On: Bool(false), Brightness: 1}, `{"on":false,"bri":1}`},
+		{"xy only", LightState{Xy: [2]float64{0.3, 0.4}}, `{"xy":[0.3,0.4]}`},
+		{"empty", LightState{}, `{}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("json.Marshal(%+v) = %s, want %s", test.state, data, test.want)
+			}
+		})
+	}
+}
+
+func TestParseResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCount int
+		wantErr   string
+		wantType  int
+	}{
+		{"array", `[{"success":{"/lights/1/state/on":true}},{"success":{"/lights/1/state/bri":200}}]`, 2, "", 0},
+		{"single object", `{"success":{"/lights/1/state/on":true}}`, 1, "", 0},
+		{"array with error", `[{"error":{"type":7,"address":"/lights/1/state/bri","description":"invalid value, 300, for parameter, bri"}}]`, 1, "invalid value", 7},
+		{"object with error", `{"error":{"type":901,"address":"/","description":"Internal error, 404"}}`, 1, "Internal error", 901},
+		{"html", "<html><body>503 Service Unavailable</body></html>", 0, "Unexpected response from hub: <html>", 0},
+		{"invalid json", `[{"success":`, 0, "Invalid response from hub", 0},
+		{"empty array", `[]`, 0, "Empty response from hub", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages, err := parseResponses([]byte(test.body))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseResponses() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("parseResponses() error = %v, want one containing %q", err, test.wantErr)
+			}
+			if len(messages) != test.wantCount {
+				t.Errorf("parseResponses() returned %d messages, want %d", len(messages), test.wantCount)
+			}
+			if test.wantType != 0 {
+				apiErr, ok := err.(*APIError)
+				if !ok || apiErr.Type != test.wantType {
+					t.Errorf("parseResponses() error = %#v, want an *APIError of type %d", err, test.wantType)
+				}
+			}
+		})
+	}
+}
+
+func TestLightStateEqual(t *testing.T) {
+	base := LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5, 0.4}}
+
+	tests := []struct {
+		name  string
+		other LightState
+		want  bool
+	}{
+		{"identical", LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5, 0.4}}, true},
+		{"x within epsilon", LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5009, 0.4}}, true},
+		{"y within epsilon", LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5, 0.3991}}, true},
+		{"x just past epsilon", LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5011, 0.4}}, false},
+		{"y just past epsilon", LightState{On: Bool(true), Brightness: 100, Xy: [2]float64{0.5, 0.3989}}, false},
+		{"different on", LightState{On: Bool(false), Brightness: 100, Xy: [2]float64{0.5, 0.4}}, false},
+		{"on unset", LightState{Brightness: 100, Xy: [2]float64{0.5, 0.4}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := base.Equal(test.other, 0.001); got != test.want {
+				t.Errorf("Equal(%v) = %v, want %v", test.other.Xy, got, test.want)
+			}
+			if got := test.other.Equal(base, 0.001); got != test.want {
+				t.Errorf("reversed Equal(%v) = %v, want %v", test.other.Xy, got, test.want)
+			}
+		})
+	}
+
+	if !base.Equal(base, 0) {
+		t.Error("Equal() with an epsilon of 0 isn't true for the same state")
+	}
+}
+
+func TestLightClone(t *testing.T) {
+	var light Light
+	light.State = LightState{On: Bool(true), TransitionTime: new(int)}
+	light.Caps.Control.ColorGamut = [][2]float64{{0.7, 0.3}, {0.2, 0.7}, {0.1, 0.1}}
+
+	clone := light.Clone()
+	*clone.State.On = false
+	*clone.State.TransitionTime = 5
+	clone.Caps.Control.ColorGamut[0][0] = 0
+
+	if !light.State.IsOn() || *light.State.TransitionTime != 0 || light.Caps.Control.ColorGamut[0][0] != 0.7 {
+		t.Errorf("changing a clone changed the original: %+v", light)
+	}
+}
+
+func TestRestGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantType int
+	}{
+		{"object", `{"name":"Lamp"}`, 0},
+		{"list of values", `["Europe/Berlin","Europe/Paris"]`, 0},
+		{"error", `[{"error":{"type":3,"address":"/lights/99","description":"resource, /lights/99, not available"}}]`, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			var item interface{}
+			err := restGet(context.Background(), server.URL, &item)
+			if test.wantType == 0 {
+				if err != nil {
+					t.Errorf("restGet() error = %v", err)
+				}
+				return
+			}
+			apiErr, ok := err.(*APIError)
+			if !ok || apiErr.Type != test.wantType {
+				t.Errorf("restGet() error = %v, want an *APIError of type %d", err, test.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package hue
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// MeetHueScene is a scene stored in a user's meethue.com account.
+type MeetHueScene struct {
+	ID     string                       `json:"id"`
+	Name   string                       `json:"name"`
+	Recipe string                       `json:"lightrecipe"`
+	Lights map[string]MeetHueLightState `json:"lights"`
+}
+
+func (m MeetHueScene) String() string {
+	return fmt.Sprintf("[%s] %s", m.ID, m.Name)
+}
+
+// MeetHueLightState is the state of one light in a MeetHueScene.
+type MeetHueLightState struct {
+	On         bool          `json:"on"`
+	Brightness int           `json:"bri"`
+	Ct         int           `json:"ct"`
+	Color      *MeetHueColor `json:"color"`
+}
+
+// MeetHueColor is an RGB color in a MeetHueLightState. Each channel ranges
+// from 0 to 1.
+type MeetHueColor struct {
+	Red   float64 `json:"red"`
+	Green float64 `json:"green"`
+	Blue  float64 `json:"blue"`
+}
+
+// RGB returns a color as a 24-bit RGB value, with each channel scaled from
+// [0, 1] to [0, 255].
+func (c MeetHueColor) RGB() (r, g, b int) {
+	scale := func(v float64) int {
+		return int(math.Round(clamp(v, 0, 1) * 255.0))
+	}
+	return scale(c.Red), scale(c.Green), scale(c.Blue)
+}
+
+// ToLightState converts a MeetHueLightState into a LightState for a light with
+// the given gamut. Colors are converted to xy values in the gamut. If the state
+// has no brightness, the brightness is taken from the color's luminance.
+func (m MeetHueLightState) ToLightState(gamut Gamut) LightState {
+	state := LightState{
+		On:         Bool(m.On),
+		Brightness: m.Brightness,
+	}
+
+	if m.Color != nil {
+		x, y, Y := gamut.ToXyY(m.Color.RGB())
+		state.Xy = [2]float64{x, y}
+		if state.Brightness == 0 {
+			state.Brightness = int(clamp(math.Round(Y*MaxBrightness), MinBrightness, MaxBrightness))
+		}
+	} else if m.Ct != 0 {
+		state.Ct = m.Ct
+	}
+
+	return state
+}
+
+// LightStates converts the light states in a scene into LightStates for a
+// hub's lights. The light IDs used by meethue.com may not match those on the
+// hub, so lightIDs maps meethue.com light IDs to hub light IDs; IDs not in the
+// map are used as-is. States for lights that aren't in lights are skipped.
+func (m MeetHueScene) LightStates(lights map[string]Light, lightIDs map[string]string) map[string]LightState {
+	states := map[string]LightState{}
+	for id, meetHueState := range m.Lights {
+		if localID, ok := lightIDs[id]; ok {
+			id = localID
+		}
+		light, ok := lights[id]
+		if !ok {
+			log.Printf("Skipping unknown light %s in scene %s", id, m.Name)
+			continue
+		}
+		states[id] = meetHueState.ToLightState(light.Gamut())
+	}
+	return states
+}
+
+// ApplyMeetHueScene sets the states of the session's hub's lights to those in
+// a MeetHueScene. See MeetHueScene.LightStates for a description of lightIDs.
+func (s *Session) ApplyMeetHueScene(scene MeetHueScene, lightIDs map[string]string) error {
+	lights, err := s.Lights()
+	if err != nil {
+		return err
+	}
+
+	for id, state := range scene.LightStates(lights, lightIDs) {
+		if err = s.reachableError(lights, id); err != nil {
+			return err
+		}
+		if _, err = s.setLightState(id, state, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recipes are the standard light states for the Philips light recipes.
+var recipes = map[string]struct{ bri, ct int }{
+	"relax":       {144, 447},
+	"reading":     {254, 346},
+	"concentrate": {254, 233},
+	"energize":    {254, 156},
+	"dimmed":      {77, 370},
+	"nightlight":  {1, 447},
+}
+
+// RecipeState returns the standard light state for a Philips light recipe,
+// such as "Relax", "Reading", "Concentrate", or "Energize". Names are
+// case-insensitive, and "read" is accepted for "reading". It returns false if
+// the recipe is unknown.
+func RecipeState(name string) (LightState, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "read" {
+		name = "reading"
+	}
+
+	recipe, ok := recipes[name]
+	if !ok {
+		return LightState{}, false
+	}
+
+	return LightState{
+		On:         Bool(true),
+		Brightness: recipe.bri,
+		Ct:         recipe.ct,
+	}, true
+}
+
+// RecipeState returns the standard light state for a scene's light recipe. It
+// returns false if the scene has no recipe or the recipe is unknown.
+func (m MeetHueScene) RecipeState() (LightState, bool) {
+	return RecipeState(m.Recipe)
+}
@@ -0,0 +1,42 @@
+package hue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeetHueLightStateToLightState(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   MeetHueLightState
+		model   string
+		wantXy  [2]float64
+		wantBri int
+		wantCt  int
+	}{
+		{"white", MeetHueLightState{Color: &MeetHueColor{1, 1, 1}}, "LCT015", [2]float64{0.3227, 0.3290}, 254, 0},
+		{"gray", MeetHueLightState{Color: &MeetHueColor{0.5, 0.5, 0.5}}, "LCT015", [2]float64{0.3227, 0.3290}, 55, 0},
+		{"red", MeetHueLightState{Color: &MeetHueColor{1, 0, 0}}, "LCT015", [2]float64{0.7006, 0.2993}, 72, 0},
+		{"red in gamut B", MeetHueLightState{Color: &MeetHueColor{1, 0, 0}}, "LCT001", [2]float64{0.6750, 0.3220}, 72, 0},
+		{"green", MeetHueLightState{Color: &MeetHueColor{0, 1, 0}}, "LCT015", [2]float64{0.1724, 0.7468}, 170, 0},
+		{"blue", MeetHueLightState{Color: &MeetHueColor{0, 0, 1}}, "LCT015", [2]float64{0.1355, 0.0399}, 12, 0},
+		{"out of range channels", MeetHueLightState{Color: &MeetHueColor{1.5, -0.2, 0}}, "LCT015", [2]float64{0.7006, 0.2993}, 72, 0},
+		{"explicit brightness", MeetHueLightState{Brightness: 200, Color: &MeetHueColor{1, 0, 0}}, "LCT015", [2]float64{0.7006, 0.2993}, 200, 0},
+		{"color temperature", MeetHueLightState{Brightness: 100, Ct: 366}, "LCT015", [2]float64{}, 100, 366},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := test.state.ToLightState(GetGamut(test.model))
+			if math.Abs(state.Xy[0]-test.wantXy[0]) > 0.0005 || math.Abs(state.Xy[1]-test.wantXy[1]) > 0.0005 {
+				t.Errorf("xy = %v, want %v", state.Xy, test.wantXy)
+			}
+			if state.Brightness != test.wantBri {
+				t.Errorf("bri = %d, want %d", state.Brightness, test.wantBri)
+			}
+			if state.Ct != test.wantCt {
+				t.Errorf("ct = %d, want %d", state.Ct, test.wantCt)
+			}
+		})
+	}
+}
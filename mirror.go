@@ -0,0 +1,80 @@
+package hue
+
+import "fmt"
+
+// MirrorLight copies the state of a source light onto target lights. The on
+// state and brightness are copied directly. Color temperatures are copied to
+// targets that support them, and colors are copied to targets that support
+// color, converted between the lights' gamuts so the targets show the same
+// color as the source even when the raw xy values would differ.
+func (s *Session) MirrorLight(sourceID string, targetIDs []string) error {
+	lights, err := s.Lights()
+	if err != nil {
+		return err
+	}
+
+	source, ok := lights[sourceID]
+	if !ok {
+		return fmt.Errorf("Light %s not found", sourceID)
+	}
+
+	states := make(map[string]LightState, len(targetIDs))
+	for _, id := range targetIDs {
+		target, ok := lights[id]
+		if !ok {
+			return fmt.Errorf("Light %s not found", id)
+		}
+		states[id] = mirroredState(source, target)
+	}
+
+	return s.SetLightStates(states)
+}
+
+// mirroredState returns the state that makes target look like source.
+func mirroredState(source, target Light) LightState {
+	state := LightState{
+		On:         source.State.On,
+		Brightness: source.State.Brightness,
+		Effect:     EffectNone,
+	}
+
+	modes := target.SupportedColorModes()
+	switch source.State.ColorMode {
+	case "":
+		// the source doesn't support color
+	case ColorModeCT:
+		if supportsMode(modes, ColorModeCT) {
+			min, max := target.CtRange()
+			state.Ct = source.State.Ct
+			if state.Ct < min {
+				state.Ct = min
+			} else if state.Ct > max {
+				state.Ct = max
+			}
+		}
+	default:
+		if supportsMode(modes, ColorModeXY) {
+			sourceGamut := source.Gamut()
+			targetGamut := target.Gamut()
+			x, y := stateXy(source.State)
+			if sourceGamut != targetGamut {
+				// convert at full brightness so rounding doesn't shift the
+				// color; the brightness is copied separately
+				r, g, b := sourceGamut.ToRGB(x, y, 1.0)
+				x, y, _ = targetGamut.ToXyY(int(r), int(g), int(b))
+			}
+			state.Xy = [2]float64{x, y}
+		}
+	}
+
+	return state
+}
+
+func supportsMode(modes []ColorMode, mode ColorMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
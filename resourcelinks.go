@@ -0,0 +1,62 @@
+package hue
+
+import (
+	"fmt"
+	"log"
+)
+
+// ResourceLink groups related resources, such as the scenes, schedules, and
+// rules that an app created for a single feature.
+type ResourceLink struct {
+	hueResourceLink
+	ID string
+}
+
+type hueResourceLink struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	ClassID     int      `json:"classid"`
+	Owner       string   `json:"owner"`
+	Recycle     bool     `json:"recycle"`
+	Links       []string `json:"links"`
+}
+
+func (r ResourceLink) String() string {
+	return fmt.Sprintf("[%s] %s", r.ID, r.Name)
+}
+
+// ResourceLinks returns a map of the ResourceLinks available from the
+// session's hub.
+func (s *Session) ResourceLinks() (links map[string]ResourceLink, err error) {
+	if err = s.get(s.URL()+"/resourcelinks", &links); err != nil {
+		return
+	}
+	for id, link := range links {
+		link.ID = id
+		links[id] = link
+	}
+	return
+}
+
+// CreateResourceLink creates a new resource link and returns its ID. Links are
+// resource paths, such as "/scenes/abc".
+func (s *Session) CreateResourceLink(name, description string, classID int, links []string) (string, error) {
+	data := map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"type":        "Link",
+		"classid":     classID,
+		"links":       links,
+	}
+	log.Printf("Creating resource link: %#v", data)
+	return s.create(s.URL()+"/resourcelinks", &data)
+}
+
+// DeleteResourceLink deletes a resource link. The linked resources are not
+// deleted.
+func (s *Session) DeleteResourceLink(id string) error {
+	resp, err := s.delete(s.URL() + "/resourcelinks/" + id)
+	log.Printf("Response: %#v", resp)
+	return err
+}
@@ -0,0 +1,57 @@
+package hue
+
+import (
+	"context"
+	"time"
+)
+
+// Color temperatures used by the wake up and sleep routines, in mireds.
+const (
+	wakeStartCt = MaxCt // warm, like the first light of sunrise
+	wakeEndCt   = 250   // cool daylight, about 4000K
+)
+
+// WakeUp gradually turns on the lights in a group over a duration d, like the
+// Hue app's wake up routine. The lights start at their lowest brightness with
+// a warm color and finish at full brightness with a cool color. WakeUp
+// returns early if ctx is cancelled.
+func (s *Session) WakeUp(ctx context.Context, groupID string, d time.Duration) error {
+	return s.WakeUpWith(ctx, groupID, d, FadeOptions{Easing: EaseInOut})
+}
+
+// WakeUpWith works like WakeUp, using the given options for the fade.
+func (s *Session) WakeUpWith(ctx context.Context, groupID string, d time.Duration, opts FadeOptions) error {
+	from := LightState{On: Bool(true), Brightness: MinBrightness, Ct: wakeStartCt}
+	to := LightState{On: Bool(true), Brightness: MaxBrightness, Ct: wakeEndCt}
+	return s.FadeGroupWith(ctx, groupID, from, to, d, int(d/MinGroupCommandInterval), opts)
+}
+
+// Sleep gradually dims the lights in a group from their current state to
+// their lowest brightness with a warm color over a duration d, like the Hue
+// app's go to sleep routine, and then turns them off. Sleep returns early if
+// ctx is cancelled.
+func (s *Session) Sleep(ctx context.Context, groupID string, d time.Duration) error {
+	return s.SleepWith(ctx, groupID, d, FadeOptions{})
+}
+
+// SleepWith works like Sleep, using the given options for the fade.
+func (s *Session) SleepWith(ctx context.Context, groupID string, d time.Duration, opts FadeOptions) error {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+
+	from := group.State
+	from.On = nil
+	from.ColorMode = ""
+	if from.Brightness == 0 {
+		from.Brightness = MaxBrightness
+	}
+	if from.Ct == 0 {
+		from.Ct = wakeStartCt
+	}
+	from.Xy = [2]float64{}
+
+	to := LightState{On: Bool(false), Brightness: MinBrightness, Ct: wakeStartCt}
+	return s.FadeGroupWith(ctx, groupID, from, to, d, int(d/MinGroupCommandInterval), opts)
+}
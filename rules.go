@@ -0,0 +1,43 @@
+package hue
+
+import "fmt"
+
+// Rule runs a set of actions when all of its conditions are met.
+type Rule struct {
+	hueRule
+	ID string `json:"id"`
+}
+
+type hueRule struct {
+	Name           string      `json:"name"`
+	Owner          string      `json:"owner"`
+	Created        HueTime     `json:"created"`
+	LastTriggered  HueTime     `json:"lasttriggered"`
+	TimesTriggered int         `json:"timestriggered"`
+	Status         string      `json:"status"`
+	Conditions     []Condition `json:"conditions"`
+	Actions        []Command   `json:"actions"`
+}
+
+// Condition is a condition that must be met for a rule to be triggered.
+type Condition struct {
+	Address  string `json:"address"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("[%s] %s", r.ID, r.Name)
+}
+
+// Rules returns a map of the Rules available from the session's hub.
+func (s *Session) Rules() (rules map[string]Rule, err error) {
+	if err = s.get(s.URL()+"/rules", &rules); err != nil {
+		return
+	}
+	for id, rule := range rules {
+		rule.ID = id
+		rules[id] = rule
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package hue
+
+import "fmt"
+
+// Schedule is a command that a hub runs at a specific time.
+type Schedule struct {
+	hueSchedule
+	ID string `json:"id"`
+}
+
+type hueSchedule struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Command     Command `json:"command"`
+	Time        string  `json:"time"`
+	LocalTime   string  `json:"localtime"`
+	Created     HueTime `json:"created"`
+	Status      string  `json:"status"`
+	AutoDelete  bool    `json:"autodelete"`
+}
+
+// Command is a request run by a schedule or rule.
+type Command struct {
+	Address string                 `json:"address"`
+	Method  string                 `json:"method"`
+	Body    map[string]interface{} `json:"body"`
+}
+
+func (s Schedule) String() string {
+	return fmt.Sprintf("[%s] %s", s.ID, s.Name)
+}
+
+// Schedules returns a map of the Schedules available from the session's hub.
+func (s *Session) Schedules() (schedules map[string]Schedule, err error) {
+	if err = s.get(s.URL()+"/schedules", &schedules); err != nil {
+		return
+	}
+	for id, schedule := range schedules {
+		schedule.ID = id
+		schedules[id] = schedule
+	}
+	return
+}
@@ -0,0 +1,239 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Sensor represents a sensor, such as a motion sensor or switch.
+type Sensor struct {
+	hueSensor
+	ID string `json:"id"`
+}
+
+type hueSensor struct {
+	Name             string       `json:"name"`
+	Type             string       `json:"type"`
+	Model            string       `json:"modelid"`
+	ManufacturerName string       `json:"manufacturername"`
+	UniqueID         string       `json:"uniqueid"`
+	SwVersion        string       `json:"swversion"`
+	State            SensorState  `json:"state"`
+	Config           SensorConfig `json:"config"`
+}
+
+// SensorState describes the state of a sensor. Which fields are meaningful
+// depends on the sensor's type.
+type SensorState struct {
+	ButtonEvent int     `json:"buttonevent"`
+	Presence    bool    `json:"presence"`
+	LightLevel  int     `json:"lightlevel"`
+	Temperature int     `json:"temperature"`
+	LastUpdated HueTime `json:"lastupdated"`
+}
+
+// SensorConfig describes the configuration of a sensor. Reachable, Battery,
+// and SensitivityMax are reported by the sensor and can't be changed. When
+// changing a sensor's configuration, fields that are nil are left unchanged.
+type SensorConfig struct {
+	On        *bool `json:"on,omitempty"`
+	Reachable bool  `json:"reachable"`
+
+	// Battery is the battery level as a percentage, or nil for sensors
+	// without a battery.
+	Battery *int `json:"battery,omitempty"`
+
+	// Sensitivity is only supported by sensors that report a SensitivityMax,
+	// such as motion sensors, and ranges from 0 to SensitivityMax.
+	Sensitivity    *int `json:"sensitivity,omitempty"`
+	SensitivityMax int  `json:"sensitivitymax,omitempty"`
+}
+
+func (s *Sensor) String() string {
+	return fmt.Sprintf("[%s] %v", s.ID, s.Name)
+}
+
+// ButtonAction is the kind of action reported by a button event.
+type ButtonAction int
+
+// Button actions. Tap switches only report ButtonPress.
+const (
+	ButtonPress ButtonAction = iota
+	ButtonHold
+	ButtonShortRelease
+	ButtonLongRelease
+)
+
+func (a ButtonAction) String() string {
+	switch a {
+	case ButtonPress:
+		return "press"
+	case ButtonHold:
+		return "hold"
+	case ButtonShortRelease:
+		return "short release"
+	case ButtonLongRelease:
+		return "long release"
+	}
+	return fmt.Sprintf("ButtonAction(%d)", int(a))
+}
+
+// ButtonEvent is a button action on a switch, such as a Hue Dimmer or Tap
+// switch. Buttons are numbered from 1.
+type ButtonEvent struct {
+	SensorID string
+	Button   int
+	Action   ButtonAction
+	Time     time.Time
+}
+
+// tapButtons maps Tap switch event codes to button numbers.
+var tapButtons = map[int]int{34: 1, 16: 2, 17: 3, 18: 4}
+
+// ParseButtonEvent decodes a switch's buttonevent code into a button number
+// and action. Dimmer-style switches report codes of the form xyyy, where x is
+// the button and yyy is the action (0 press, 1 hold, 2 short release, 3 long
+// release); Tap switches report one code per button. It returns false if the
+// code isn't recognized.
+func ParseButtonEvent(code int) (button int, action ButtonAction, ok bool) {
+	if button, ok = tapButtons[code]; ok {
+		return button, ButtonPress, true
+	}
+
+	button, action = code/1000, ButtonAction(code%1000)
+	if button < 1 || action > ButtonLongRelease {
+		return 0, 0, false
+	}
+	return button, action, true
+}
+
+// IsSwitch returns true if a sensor is a switch that reports button events.
+func (s *Sensor) IsSwitch() bool {
+	return s.Type == "ZLLSwitch" || s.Type == "ZGPSwitch"
+}
+
+// ButtonEvent returns the last button event reported by a switch. It returns
+// false if the sensor isn't a switch or hasn't reported a recognized event.
+func (s *Sensor) ButtonEvent() (ButtonEvent, bool) {
+	if !s.IsSwitch() {
+		return ButtonEvent{}, false
+	}
+	button, action, ok := ParseButtonEvent(s.State.ButtonEvent)
+	if !ok {
+		return ButtonEvent{}, false
+	}
+	return ButtonEvent{s.ID, button, action, s.State.LastUpdated.Time}, true
+}
+
+// Sensors returns a map of the Sensors available from the session's hub.
+func (s *Session) Sensors() (sensors map[string]Sensor, err error) {
+	if err = s.get(s.URL()+"/sensors", &sensors); err != nil {
+		return
+	}
+	for id, sensor := range sensors {
+		sensor.ID = id
+		sensors[id] = sensor
+	}
+	return
+}
+
+// GetSensor returns a specific sensor.
+func (s *Session) GetSensor(id string) (sensor Sensor, err error) {
+	if err = s.get(s.URL()+"/sensors/"+id, &sensor); err != nil {
+		return
+	}
+	sensor.ID = id
+	return
+}
+
+// SetSensorConfig changes the configuration of a sensor. Only the On and
+// Sensitivity fields that are set are sent. The sensitivity can only be set on
+// sensors that support it, and must not exceed the sensor's SensitivityMax.
+func (s *Session) SetSensorConfig(id string, cfg SensorConfig) error {
+	data := map[string]interface{}{}
+	if cfg.On != nil {
+		data["on"] = *cfg.On
+	}
+
+	if cfg.Sensitivity != nil {
+		sensor, err := s.GetSensor(id)
+		if err != nil {
+			return err
+		}
+		max := sensor.Config.SensitivityMax
+		if max <= 0 {
+			return fmt.Errorf("Sensor %s doesn't support sensitivity", id)
+		}
+		if *cfg.Sensitivity < 0 || *cfg.Sensitivity > max {
+			return fmt.Errorf("Invalid sensitivity %d; must be from 0 to %d", *cfg.Sensitivity, max)
+		}
+		data["sensitivity"] = *cfg.Sensitivity
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	log.Printf("Setting sensor config to: %#v", data)
+	resp, err := s.put(s.URL()+"/sensors/"+id+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// WatchButtons polls the session's hub for switch button events, sending each
+// new event on the returned channel. The channel is closed when ctx is
+// cancelled. Events that occur between polls, other than the last one for each
+// switch, are missed, so interval should be short (but not so short that it
+// overloads the hub).
+func (s *Session) WatchButtons(ctx context.Context, interval time.Duration) <-chan ButtonEvent {
+	events := make(chan ButtonEvent)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// the last update time of each switch; events already reported when
+		// watching starts are ignored
+		seen := map[string]time.Time{}
+		first := true
+
+		for {
+			sensors, err := s.Sensors()
+			if err != nil {
+				log.Printf("Error polling sensors: %v", err)
+			}
+
+			for id, sensor := range sensors {
+				event, ok := sensor.ButtonEvent()
+				if !ok || seen[id].Equal(event.Time) {
+					continue
+				}
+				seen[id] = event.Time
+				if first {
+					continue
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err == nil {
+				first = false
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events
+}
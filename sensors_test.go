@@ -0,0 +1,52 @@
+package hue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetSensorConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SensorConfig
+		want    string
+		wantErr bool
+	}{
+		{"sensitivity only", SensorConfig{Sensitivity: Int(1)}, `{"sensitivity":1}`, false},
+		{"off only", SensorConfig{On: Bool(false)}, `{"on":false}`, false},
+		{"both", SensorConfig{On: Bool(true), Sensitivity: Int(0)}, `{"on":true,"sensitivity":0}`, false},
+		{"sensitivity too high", SensorConfig{Sensitivity: Int(3)}, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "PUT" {
+					body, _ := ioutil.ReadAll(r.Body)
+					sent = string(body)
+					w.Write([]byte(`[{"success":{}}]`))
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"type":   "ZLLPresence",
+					"config": map[string]interface{}{"on": true, "sensitivity": 2, "sensitivitymax": 2},
+				})
+			}))
+			defer server.Close()
+
+			session := OpenSession(strings.TrimPrefix(server.URL, "http://"), "user")
+			err := session.SetSensorConfig("1", test.cfg)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("SetSensorConfig() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if sent != test.want {
+				t.Errorf("SetSensorConfig() sent %q, want %q", sent, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package hue_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	hue "github.com/jason0x43/go-hue"
+	"github.com/jason0x43/go-hue/hue/huetest"
+)
+
+// TestSessionConcurrentUse is most useful with the race detector enabled
+// (go test -race).
+func TestSessionConcurrentUse(t *testing.T) {
+	bridge := huetest.NewMockBridge()
+	defer bridge.Close()
+	for i := 1; i <= 4; i++ {
+		bridge.AddLight(fmt.Sprint(i), fmt.Sprintf("Light %d", i))
+	}
+
+	session := bridge.Session()
+	copied := session
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprint(i%4 + 1)
+			state := hue.LightState{Brightness: i%hue.MaxBrightness + 1}
+			if err := session.SetLightState(id, state); err != nil {
+				errs <- err
+			}
+			if _, err := copied.Lights(); err != nil {
+				errs <- err
+			}
+		}(i)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session.SetRetries(i % 3)
+			copied.SetClampStates(i%2 == 0)
+			session.SetCaching(i%2 == 1)
+			copied.SetTraceFunc(func(hue.RequestTrace) {})
+			session.Retries()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSessionCopiesShareSettings(t *testing.T) {
+	session := hue.OpenSession("127.0.0.1", "user")
+	copied := session
+	copied.SetRetries(5)
+	if retries := session.Retries(); retries != 5 {
+		t.Errorf("Retries() = %d, want 5", retries)
+	}
+}
+
+func TestZeroSession(t *testing.T) {
+	var session hue.Session
+	if retries := session.Retries(); retries != hue.DefaultRetries {
+		t.Errorf("Retries() = %d, want %d", retries, hue.DefaultRetries)
+	}
+	session.SetRetries(0)
+	session.SetDryRun(true)
+	if err := session.SetLightState("1", hue.LightState{On: hue.Bool(true)}); err != nil {
+		t.Errorf("SetLightState() in dry-run mode: %v", err)
+	}
+	if n := len(session.RecordedCommands()); n != 1 {
+		t.Errorf("RecordedCommands() has %d commands, want 1", n)
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	bridge := huetest.NewMockBridge()
+	defer bridge.Close()
+	light := bridge.AddLight("1", "Lamp")
+	light.State.Reachable = false
+	bridge.Lock()
+	bridge.Lights["1"] = light
+	bridge.Unlock()
+
+	session := bridge.Session()
+	state := hue.LightState{On: hue.Bool(true)}
+
+	if err := session.SetLightState("1", state); err != nil {
+		t.Errorf("SetLightState() without checking = %v, want nil", err)
+	}
+
+	session.SetCheckReachable(true)
+	if err := session.SetLightState("1", state); err != hue.ErrLightUnreachable {
+		t.Errorf("SetLightState() = %v, want ErrLightUnreachable", err)
+	}
+	if _, err := session.SetLightStateReturning("1", state); err != hue.ErrLightUnreachable {
+		t.Errorf("SetLightStateReturning() = %v, want ErrLightUnreachable", err)
+	}
+	if _, err := session.EnsureLightState("1", hue.LightState{On: hue.Bool(false)}); err != hue.ErrLightUnreachable {
+		t.Errorf("EnsureLightState() = %v, want ErrLightUnreachable", err)
+	}
+	if err := session.SetLightStates(map[string]hue.LightState{"1": state}); err != hue.ErrLightUnreachable {
+		t.Errorf("SetLightStates() = %v, want ErrLightUnreachable", err)
+	}
+}
+
+func TestSetLightStateUsesLightCtRange(t *testing.T) {
+	bridge := huetest.NewMockBridge()
+	defer bridge.Close()
+	light := bridge.AddLight("1", "Lamp")
+	light.Caps.Control.Ct.Min = 153
+	light.Caps.Control.Ct.Max = 454
+	bridge.Lock()
+	bridge.Lights["1"] = light
+	bridge.Unlock()
+
+	session := bridge.Session()
+	state := hue.LightState{Ct: 480}
+
+	// until the light has been read, only the hub's range is known
+	if err := session.SetLightState("1", state); err != nil {
+		t.Errorf("SetLightState() before reading the light = %v, want nil", err)
+	}
+
+	if _, err := session.GetLight("1"); err != nil {
+		t.Fatal(err)
+	}
+	err := session.SetLightState("1", state)
+	if err == nil || !strings.Contains(err.Error(), "ct 480") {
+		t.Errorf("SetLightState() = %v, want an error naming ct 480", err)
+	}
+
+	// lights read through the cache are remembered too
+	cached := bridge.Session()
+	cached.SetCaching(true)
+	if _, _, err := cached.LightsChanged(); err != nil {
+		t.Fatal(err)
+	}
+	err = cached.SetLightState("1", state)
+	if err == nil || !strings.Contains(err.Error(), "ct 480") {
+		t.Errorf("SetLightState() after LightsChanged() = %v, want an error naming ct 480", err)
+	}
+}
+
+func TestMissingResources(t *testing.T) {
+	bridge := huetest.NewMockBridge()
+	defer bridge.Close()
+	bridge.AddLight("1", "Lamp")
+	session := bridge.Session()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetScene", func() error {
+			_, err := session.GetScene("missing")
+			return err
+		}},
+		{"SetScene", func() error {
+			return session.SetScene("missing")
+		}},
+		{"SetSceneThen", func() error {
+			return session.SetSceneThen("missing", hue.LightState{Brightness: 100})
+		}},
+		{"ApplySceneToLights", func() error {
+			return session.ApplySceneToLights("missing", []string{"1"})
+		}},
+		{"UpgradeScene", func() error {
+			_, err := session.UpgradeScene("missing")
+			return err
+		}},
+		{"GetLight", func() error {
+			_, err := session.GetLight("99")
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			apiErr, ok := err.(*hue.APIError)
+			if !ok || apiErr.Type != 3 {
+				t.Errorf("%s() error = %v, want an *APIError of type 3", test.name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Snapshot is the lights, groups, and scenes on a hub at one time.
+type Snapshot struct {
+	Lights map[string]Light
+	Groups map[string]Group
+	Scenes map[string]Scene
+}
+
+// SnapshotError is returned by Session.Snapshot when some parts of a snapshot
+// couldn't be read. The parts that were read are still returned.
+type SnapshotError struct {
+	// Errors maps the parts that failed ("lights", "groups", or "scenes") to
+	// their errors.
+	Errors map[string]error
+}
+
+func (e *SnapshotError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, part := range []string{"lights", "groups", "scenes"} {
+		if err, ok := e.Errors[part]; ok {
+			parts = append(parts, fmt.Sprintf("%s: %v", part, err))
+		}
+	}
+	return "Error reading " + strings.Join(parts, "; ")
+}
+
+// Snapshot reads the lights, groups, and scenes on the session's hub
+// concurrently. If any of them can't be read, the others are still returned
+// along with a *SnapshotError describing which failed. Cancelling ctx cancels
+// any reads that are still running.
+func (s *Session) Snapshot(ctx context.Context) (snapshot Snapshot, err error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := map[string]error{}
+
+	read := func(part string, f func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(); err != nil {
+				mu.Lock()
+				errs[part] = err
+				mu.Unlock()
+			}
+		}()
+	}
+
+	read("lights", func() (err error) {
+		snapshot.Lights, err = s.lights(ctx)
+		return
+	})
+	read("groups", func() (err error) {
+		snapshot.Groups, err = s.groups(ctx)
+		return
+	})
+	read("scenes", func() (err error) {
+		snapshot.Scenes, err = s.scenes(ctx)
+		return
+	})
+	wg.Wait()
+
+	if len(errs) > 0 {
+		err = &SnapshotError{errs}
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package hue
+
+import (
+	"fmt"
+	"log"
+)
+
+// Light startup modes, which determine what a light does when it's powered
+// on, such as after a power cut.
+const (
+	// StartupSafety turns the light on at full brightness with a warm white
+	// color. This is the default.
+	StartupSafety = "safety"
+
+	// StartupPowerfail restores the state the light was in when it lost
+	// power, including being off.
+	StartupPowerfail = "powerfail"
+
+	// StartupLastOnState restores the last state the light was in while it
+	// was on.
+	StartupLastOnState = "lastonstate"
+
+	// StartupCustom uses custom settings, which must be configured through
+	// another app.
+	StartupCustom = "custom"
+)
+
+// LightConfig describes the configuration of a light.
+type LightConfig struct {
+	Archetype string       `json:"archetype"`
+	Function  string       `json:"function"`
+	Direction string       `json:"direction"`
+	Startup   LightStartup `json:"startup"`
+}
+
+// LightStartup describes what a light does when it's powered on. Lights that
+// don't support startup behavior report an empty Mode.
+type LightStartup struct {
+	Mode       string `json:"mode"`
+	Configured bool   `json:"configured"`
+}
+
+// LightStartup returns the startup behavior of a specific light.
+func (s *Session) LightStartup(id string) (LightStartup, error) {
+	light, err := s.GetLight(id)
+	return light.Config.Startup, err
+}
+
+// SetLightStartup sets the startup behavior of a specific light. The mode must
+// be StartupSafety, StartupPowerfail, or StartupLastOnState; StartupCustom
+// requires settings that can't be set here.
+func (s *Session) SetLightStartup(id string, mode string) error {
+	switch mode {
+	case StartupSafety, StartupPowerfail, StartupLastOnState:
+	default:
+		return fmt.Errorf("Invalid startup mode '%s'", mode)
+	}
+
+	startup, err := s.LightStartup(id)
+	if err != nil {
+		return err
+	}
+	if startup.Mode == "" {
+		return fmt.Errorf("Light %s doesn't support startup modes", id)
+	}
+
+	data := map[string]interface{}{
+		"startup": map[string]string{"mode": mode},
+	}
+	resp, err := s.put(s.URL()+"/lights/"+id+"/config", &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
@@ -0,0 +1,79 @@
+package hue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// ErrStreamOwned is returned by StartStreaming when another client is
+// already streaming to an entertainment group.
+var ErrStreamOwned = errors.New("Another client is streaming to the group")
+
+// GroupStream describes the streaming status of an entertainment group.
+type GroupStream struct {
+	ProxyMode string `json:"proxymode"`
+	ProxyNode string `json:"proxynode"`
+	Active    bool   `json:"active"`
+	Owner     string `json:"owner"`
+}
+
+// StartStreaming activates streaming for an entertainment group, which must
+// be done before streaming light updates to the group. Only one client may
+// stream to a group at a time; if another client is already streaming,
+// ErrStreamOwned is returned.
+//
+// The returned Closer stops streaming, the same as StopStreaming. It is safe
+// to call more than once, so it can be deferred even if StopStreaming is also
+// called.
+func (s *Session) StartStreaming(groupID string) (io.Closer, error) {
+	group, err := s.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.Type != "Entertainment" || group.Stream == nil {
+		return nil, fmt.Errorf("Group %s is not an entertainment group", groupID)
+	}
+	if group.Stream.Active && group.Stream.Owner != s.username {
+		return nil, ErrStreamOwned
+	}
+
+	if err := s.setStreaming(groupID, true); err != nil {
+		return nil, err
+	}
+	return &streamCloser{session: s, groupID: groupID}, nil
+}
+
+// StopStreaming deactivates streaming for an entertainment group.
+func (s *Session) StopStreaming(groupID string) error {
+	return s.setStreaming(groupID, false)
+}
+
+// support functions ///////////////////////////////////////////////////
+
+func (s *Session) setStreaming(groupID string, active bool) error {
+	data := map[string]interface{}{
+		"stream": map[string]bool{"active": active},
+	}
+	log.Printf("Setting group %s streaming to %v", groupID, active)
+	resp, err := s.put(s.URL()+"/groups/"+groupID, &data)
+	log.Printf("Response: %#v", resp)
+	return err
+}
+
+// streamCloser stops streaming to a group when closed.
+type streamCloser struct {
+	session *Session
+	groupID string
+	once    sync.Once
+	err     error
+}
+
+func (c *streamCloser) Close() error {
+	c.once.Do(func() {
+		c.err = c.session.StopStreaming(c.groupID)
+	})
+	return c.err
+}
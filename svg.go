@@ -0,0 +1,59 @@
+package hue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Corners returns the xy coordinates of a gamut's red, green, and blue
+// corners.
+func (gamut *Gamut) Corners() [3][2]float64 {
+	return [3][2]float64{
+		{gamut.red.x, gamut.red.y},
+		{gamut.green.x, gamut.green.y},
+		{gamut.blue.x, gamut.blue.y},
+	}
+}
+
+// PlotSVG returns an SVG image of a gamut's triangle in the CIE xy plane,
+// which is useful for seeing why a color was clamped. Each of the given points
+// is drawn as a dot; points outside the gamut are drawn hollow, with a line to
+// the point they're clamped to.
+func (gamut *Gamut) PlotSVG(points ...[2]float64) string {
+	const size = 500.0
+
+	// convert xy coordinates, which range from 0 to 1 with y increasing
+	// upwards, to SVG coordinates
+	px := func(x float64) float64 { return x * size }
+	py := func(y float64) float64 { return size - y*size }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n", size, size, size, size)
+	fmt.Fprintf(&b, `<rect width="%g" height="%g" fill="white" stroke="#ccc"/>`+"\n", size, size)
+
+	corners := gamut.Corners()
+	fmt.Fprintf(&b, `<polygon points="%g,%g %g,%g %g,%g" fill="#eee" stroke="black"/>`+"\n",
+		px(corners[0][0]), py(corners[0][1]),
+		px(corners[1][0]), py(corners[1][1]),
+		px(corners[2][0]), py(corners[2][1]))
+	for i, color := range []string{"red", "green", "blue"} {
+		fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="4" fill="%s"/>`+"\n", px(corners[i][0]), py(corners[i][1]), color)
+	}
+
+	fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="3" fill="none" stroke="gray"/>`+"\n", px(whitePoint.x), py(whitePoint.y))
+
+	for _, p := range points {
+		if gamut.Contains(p[0], p[1]) {
+			fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="3" fill="black"/>`+"\n", px(p[0]), py(p[1]))
+			continue
+		}
+		cx, cy := gamut.Clamp(p[0], p[1])
+		fmt.Fprintf(&b, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="gray" stroke-dasharray="4"/>`+"\n",
+			px(p[0]), py(p[1]), px(cx), py(cy))
+		fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="3" fill="none" stroke="black"/>`+"\n", px(p[0]), py(p[1]))
+		fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="3" fill="black"/>`+"\n", px(cx), py(cy))
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
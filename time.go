@@ -0,0 +1,43 @@
+package hue
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HueTime is a timestamp reported by a hub, such as the time a scene was last
+// updated. Hubs report timestamps in UTC without a timezone, and use "none"
+// for timestamps that aren't set; these are decoded as the zero time.
+type HueTime struct {
+	time.Time
+}
+
+// UnmarshalJSON decodes a hub timestamp.
+func (t *HueTime) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	if value == nil || *value == "" || *value == "none" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := time.Parse(hubTimeFormat, *value)
+	if err != nil {
+		if parsed, err = time.Parse(time.RFC3339, *value); err != nil {
+			return err
+		}
+	}
+	t.Time = parsed
+	return nil
+}
+
+// MarshalJSON encodes a timestamp in the format used by hubs.
+func (t HueTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return json.Marshal("none")
+	}
+	return json.Marshal(t.UTC().Format(hubTimeFormat))
+}
@@ -0,0 +1,102 @@
+package hue
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTrace records the timing of a request to a hub. Durations for steps
+// that didn't happen, such as DNS lookups for IP addresses or connecting when
+// a connection was reused, are 0.
+type RequestTrace struct {
+	Method       string
+	URL          string
+	ReusedConn   bool
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+
+	// FirstByte is the time from the start of the request until the first
+	// byte of the response was received.
+	FirstByte time.Duration
+
+	// Total is the time from the start of the request until the response
+	// was read.
+	Total time.Duration
+}
+
+// SetTraceFunc sets a function that is called with timing information after
+// each request the session makes. Passing nil disables tracing.
+func (s *Session) SetTraceFunc(f func(RequestTrace)) {
+	st := s.settings()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.traceFunc = f
+}
+
+// traceContext returns a context for a request, derived from parent, along
+// with a function to call when the request is complete. If tracing is enabled,
+// the context records the request's timing, and the function reports it.
+func (s *Session) traceContext(parent context.Context, method string, url string) (context.Context, func()) {
+	st := s.settings()
+	st.mu.RLock()
+	f := st.traceFunc
+	st.mu.RUnlock()
+
+	if f == nil {
+		return parent, func() {}
+	}
+
+	trace := RequestTrace{Method: method, URL: url}
+	start := time.Now()
+	var dnsStart, connectStart, tlsStart time.Time
+
+	// The transport may finish dialing a connection for this request after
+	// the request has been given another one, so the hooks can run
+	// concurrently with each other and with the function returned here.
+	var mu sync.Mutex
+	record := func(update func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		update()
+	}
+
+	ctx := httptrace.WithClientTrace(parent, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { trace.ReusedConn = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { trace.DNS = time.Since(dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() { connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			record(func() { trace.Connect = time.Since(connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			record(func() { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { trace.TLSHandshake = time.Since(tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { trace.FirstByte = time.Since(start) })
+		},
+	})
+
+	return ctx, func() {
+		var done RequestTrace
+		record(func() {
+			trace.Total = time.Since(start)
+			done = trace
+		})
+		f(done)
+	}
+}
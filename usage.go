@@ -0,0 +1,96 @@
+package hue
+
+import (
+	"sort"
+	"strings"
+)
+
+// UsageReport lists the IDs of the resources that refer to a light.
+type UsageReport struct {
+	Groups    []string
+	Scenes    []string
+	Schedules []string
+	Rules     []string
+}
+
+// IsEmpty returns true if no resources refer to the light.
+func (r UsageReport) IsEmpty() bool {
+	return len(r.Groups) == 0 && len(r.Scenes) == 0 && len(r.Schedules) == 0 && len(r.Rules) == 0
+}
+
+// LightUsage returns the groups, scenes, schedules, and rules that refer to a
+// specific light, which will be affected if the light is deleted or reset.
+// Schedules and rules are included if any of their commands target the light
+// directly.
+func (s *Session) LightUsage(id string) (report UsageReport, err error) {
+	groups, err := s.Groups()
+	if err != nil {
+		return
+	}
+	for groupID, group := range groups {
+		if contains(group.Lights, id) {
+			report.Groups = append(report.Groups, groupID)
+		}
+	}
+
+	scenes, err := s.Scenes()
+	if err != nil {
+		return
+	}
+	for sceneID, scene := range scenes {
+		if contains(scene.Lights, id) {
+			report.Scenes = append(report.Scenes, sceneID)
+		}
+	}
+
+	schedules, err := s.Schedules()
+	if err != nil {
+		return
+	}
+	for scheduleID, schedule := range schedules {
+		if addressesLight(schedule.Command.Address, id) {
+			report.Schedules = append(report.Schedules, scheduleID)
+		}
+	}
+
+	rules, err := s.Rules()
+	if err != nil {
+		return
+	}
+	for ruleID, rule := range rules {
+		for _, action := range rule.Actions {
+			if addressesLight(action.Address, id) {
+				report.Rules = append(report.Rules, ruleID)
+				break
+			}
+		}
+	}
+
+	sort.Strings(report.Groups)
+	sort.Strings(report.Scenes)
+	sort.Strings(report.Schedules)
+	sort.Strings(report.Rules)
+	return
+}
+
+// contains returns true if values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// addressesLight returns true if a command address, such as
+// "/api/<username>/lights/1/state" or "/lights/1/state", refers to a light.
+func addressesLight(address string, id string) bool {
+	prefix := "/lights/" + id
+	i := strings.Index(address, prefix)
+	if i < 0 {
+		return false
+	}
+	rest := address[i+len(prefix):]
+	return rest == "" || strings.HasPrefix(rest, "/")
+}
@@ -0,0 +1,34 @@
+package hue
+
+import (
+	"context"
+	"time"
+)
+
+// MinPollInterval is the minimum time between requests made when polling a
+// light's state.
+const MinPollInterval = 250 * time.Millisecond
+
+// WaitForState polls a light until pred returns true for it, checking every
+// MinPollInterval. It returns ctx's error if ctx is cancelled or expires
+// first, or the error from reading the light if that fails.
+func (s *Session) WaitForState(ctx context.Context, id string, pred func(Light) bool) error {
+	ticker := time.NewTicker(MinPollInterval)
+	defer ticker.Stop()
+
+	for {
+		light, err := s.GetLight(id)
+		if err != nil {
+			return err
+		}
+		if pred(light) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}